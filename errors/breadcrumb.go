@@ -0,0 +1,63 @@
+package errors
+
+import (
+	"fmt"
+	"time"
+)
+
+var maxBreadcrumbs int
+
+// SetGlobalMaxBreadcrumbs limits how many breadcrumbs an error keeps. When the limit is exceeded
+// the oldest breadcrumbs are dropped first. A limit of zero or less keeps every breadcrumb.
+func SetGlobalMaxBreadcrumbs(max int) {
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	maxBreadcrumbs = max
+}
+
+func getMaxBreadcrumbs() int {
+	outputSettingsMutex.RLock()
+	defer outputSettingsMutex.RUnlock()
+	return maxBreadcrumbs
+}
+
+// Breadcrumb is an event that happened before an error occurred, recorded to help debug the lead up to the error.
+type Breadcrumb struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Message   string                 `json:"message"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// String renders the breadcrumb with its timestamp in the global timestamp format.
+func (b Breadcrumb) String() string {
+	return b.format(b.Timestamp.Format(getTimestampFormat()))
+}
+
+// format renders the breadcrumb with its timestamp already formatted.
+func (b Breadcrumb) format(timestamp string) string {
+	if len(b.Data) == 0 {
+		return fmt.Sprintf("%s - %s", timestamp, b.Message)
+	}
+	return fmt.Sprintf("%s - %s - %v", timestamp, b.Message, b.Data)
+}
+
+// AddBreadcrumb appends a timestamped breadcrumb to the error. Breadcrumbs are kept in the order they were added.
+func (e richError) AddBreadcrumb(message string, data map[string]interface{}) RichError {
+	breadcrumbs := make([]Breadcrumb, len(e.Breadcrumbs), len(e.Breadcrumbs)+1)
+	copy(breadcrumbs, e.Breadcrumbs)
+	breadcrumb := Breadcrumb{
+		Timestamp: currentTime(),
+		Message:   message,
+		Data:      copyMetaData(data),
+	}
+	breadcrumbs = append(breadcrumbs, breadcrumb)
+	if max := getMaxBreadcrumbs(); max > 0 && len(breadcrumbs) > max {
+		breadcrumbs = breadcrumbs[len(breadcrumbs)-max:]
+	}
+	e.Breadcrumbs = breadcrumbs
+	return e.withNewID()
+}
+
+func (e richError) GetBreadcrumbs() []Breadcrumb {
+	return e.Breadcrumbs
+}
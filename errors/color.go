@@ -0,0 +1,74 @@
+package errors
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+)
+
+var (
+	colorEnabled = detectColorSupport()
+	colorLabels  = map[string]string{
+		"ERRCODE: ": ansiBold + ansiRed,
+		"MESSAGE: ": ansiBold,
+		"SOURCE: ":  ansiCyan,
+		"STACK: ":   ansiYellow,
+	}
+)
+
+// SetGlobalColorEnabled overrides whether ColorOutput emits ANSI color codes.
+// By default colors are enabled when stdout is a terminal and the NO_COLOR environment variable is not set.
+func SetGlobalColorEnabled(enabled bool) {
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	colorEnabled = enabled
+}
+
+func getColorEnabled() bool {
+	outputSettingsMutex.RLock()
+	defer outputSettingsMutex.RUnlock()
+	return colorEnabled
+}
+
+func detectColorSupport() bool {
+	if _, noColor := os.LookupEnv("NO_COLOR"); noColor {
+		return false
+	}
+	stdoutInfo, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return stdoutInfo.Mode()&os.ModeCharDevice != 0
+}
+
+// colorOutputString renders FullOutputFormatted with the section labels colored and the timestamp dimmed.
+// When colors are disabled it is the same as FullOutputFormatted.
+func (e richError) colorOutputString() string {
+	output := e.fullOutputString("\n", "\t")
+	if !getColorEnabled() {
+		return output
+	}
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "TIMESTAMP: ") {
+			lines[i] = fmt.Sprintf("%s%s%s", ansiDim, line, ansiReset)
+			continue
+		}
+		for label, color := range colorLabels {
+			if strings.HasPrefix(line, label) {
+				lines[i] = fmt.Sprintf("%s%s%s%s", color, label, ansiReset, strings.TrimPrefix(line, label))
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,51 @@
+package errors
+
+import "context"
+
+// contextKey is a context key registered with RegisterContextKey and the metadata name its value is added under.
+type contextKey struct {
+	key          interface{}
+	metaDataName string
+}
+
+var contextKeys []contextKey
+
+// RegisterContextKey registers a context key whose value FromContext adds to the error metadata as metaDataName,
+// e.g. RegisterContextKey(requestIDKey{}, "requestId"). Registering a key again replaces its metadata name.
+// Keys are usually registered once while the application starts.
+func RegisterContextKey(key interface{}, metaDataName string) {
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	// the keys are copied so a slice returned by getContextKeys is never modified.
+	registeredKeys := make([]contextKey, len(contextKeys), len(contextKeys)+1)
+	copy(registeredKeys, contextKeys)
+	for i, registeredKey := range registeredKeys {
+		if registeredKey.key == key {
+			registeredKeys[i].metaDataName = metaDataName
+			contextKeys = registeredKeys
+			return
+		}
+	}
+	contextKeys = append(registeredKeys, contextKey{key: key, metaDataName: metaDataName})
+}
+
+func getContextKeys() []contextKey {
+	outputSettingsMutex.RLock()
+	defer outputSettingsMutex.RUnlock()
+	return contextKeys
+}
+
+// FromContext creates a new rich error with the value of every registered context key present in ctx added to its
+// metadata, so request scoped data like request, trace and user IDs is included without each call site adding it.
+func FromContext(ctx context.Context, code, message string) RichError {
+	err := NewRichError(code, message)
+	if ctx == nil {
+		return err
+	}
+	for _, registeredKey := range getContextKeys() {
+		if value := ctx.Value(registeredKey.key); value != nil {
+			err = err.AddMetaData(registeredKey.metaDataName, value)
+		}
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+package errors
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+)
+
+// captureGoroutineID is atomic because it is read by every stack capture and may be set concurrently.
+var captureGoroutineID atomic.Bool
+
+// SetCaptureGoroutineID sets whether WithStack records the ID of the goroutine that captured the stack. It is off by
+// default because the ID has to be parsed from the header of runtime.Stack, which adds to the cost of every capture.
+func SetCaptureGoroutineID(enabled bool) {
+	captureGoroutineID.Store(enabled)
+}
+
+var goroutinePrefix = []byte("goroutine ")
+
+// currentGoroutineID parses the ID of the current goroutine from the "goroutine 123 [running]:" header of runtime.Stack.
+// It returns 0 if the header can not be parsed.
+func currentGoroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, goroutinePrefix)
+	if end := bytes.IndexByte(buf, ' '); end > 0 {
+		buf = buf[:end]
+	}
+	id, err := strconv.ParseUint(string(buf), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// GetGoroutineID returns the ID of the goroutine that captured the stack of the error. It is only recorded
+// by WithStack when SetCaptureGoroutineID is enabled.
+func (e richError) GetGoroutineID() (uint64, bool) {
+	return e.GoroutineID, e.GoroutineID != 0
+}
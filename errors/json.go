@@ -0,0 +1,211 @@
+package errors
+
+import (
+	"bytes"
+	"encoding/json"
+	goerrors "errors"
+)
+
+// jsonIndent is the indentation of the JSONOutput format.
+const jsonIndent = "  "
+
+// richErrorAlias has the same fields as richError without its methods so it can be embedded in jsonRichError.
+type richErrorAlias richError
+
+// jsonRichError is the JSON representation of a richError.
+// Its InnerErrors field shadows the embedded one so inner errors can be nested recursively.
+type jsonRichError struct {
+	richErrorAlias
+	InnerErrors   []interface{}      `json:"innerErrors"`
+	RelatedErrors []jsonRelatedError `json:"relatedErrors,omitempty"`
+	Cause         interface{}        `json:"cause,omitempty"`
+	OutputFormat  string             `json:"outputFormat"`
+	// AllTags is only set when SetGlobalIncludeAllTags is enabled.
+	AllTags []string `json:"allTags,omitempty"`
+}
+
+// jsonRelatedError is the JSON representation of a RelatedError.
+type jsonRelatedError struct {
+	Relation string      `json:"relation"`
+	Error    interface{} `json:"error"`
+}
+
+// jsonPlainError is the JSON representation of an inner error that is not a rich error.
+type jsonPlainError struct {
+	Message string `json:"message"`
+}
+
+// MarshalJSON renders inner rich errors as nested objects and other inner errors as {"message": err.Error()}.
+func (e richError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.jsonValue(newRenderState()))
+}
+
+// jsonValue returns the JSON representation of e, or a plain error with a message explaining why
+// it was not rendered when the maximum render depth is reached or e contains itself.
+func (e richError) jsonValue(state *renderState) interface{} {
+	leave, message := state.enter(e)
+	if leave == nil {
+		return jsonPlainError{Message: message}
+	}
+	defer leave()
+	return e.toJSONRepresentation(state)
+}
+
+func (e richError) toJSONRepresentation(state *renderState) jsonRichError {
+	alias := richErrorAlias(e)
+	alias.Severity = e.GetSeverity()
+	alias.MetaData = e.redactedMetaData()
+	alias.Stack = e.stack()
+	jsonErr := jsonRichError{
+		richErrorAlias: alias,
+		OutputFormat:   e.getErrorOutputFormat().String(),
+	}
+	if getIncludeAllTags() {
+		jsonErr.AllTags = e.GetAllTags()
+	}
+	if e.InnerErrors != nil {
+		jsonErr.InnerErrors = make([]interface{}, 0, len(e.InnerErrors))
+	}
+	for _, err := range e.InnerErrors {
+		jsonErr.InnerErrors = append(jsonErr.InnerErrors, jsonErrorValue(e.inheritRedactedKeys(err), state))
+	}
+	if e.Cause != nil {
+		jsonErr.Cause = jsonErrorValue(e.inheritRedactedKeys(e.Cause), state)
+	}
+	for _, relatedErr := range e.RelatedErrors {
+		jsonErr.RelatedErrors = append(jsonErr.RelatedErrors, jsonRelatedError{
+			Relation: relatedErr.Relation,
+			Error:    jsonErrorValue(e.inheritRedactedKeys(relatedErr.Err), state),
+		})
+	}
+	return jsonErr
+}
+
+// jsonErrorValue returns the value used to represent an inner or related error in JSON.
+func jsonErrorValue(err error, state *renderState) interface{} {
+	switch innerErr := err.(type) {
+	case richError:
+		return innerErr.jsonValue(state)
+	case ReadOnlyRichError:
+		return innerErr
+	case nil:
+		return nil
+	default:
+		return jsonPlainError{Message: innerErr.Error()}
+	}
+}
+
+func (e richError) jsonOutputString(indent string) string {
+	return e.jsonOutputStringWithState(indent, newRenderState())
+}
+
+// jsonOutputStringWithState renders e as JSON indented with indent, or on a single line when indent is empty.
+func (e richError) jsonOutputStringWithState(indent string, state *renderState) string {
+	var jsonData []byte
+	var err error
+	if indent == "" {
+		jsonData, err = json.Marshal(e.jsonValue(state))
+	} else {
+		jsonData, err = json.MarshalIndent(e.jsonValue(state), "", indent)
+	}
+	if err != nil {
+		// Metadata values that can not be marshaled should not prevent the error from being output.
+		fallbackData, _ := json.Marshal(map[string]string{
+			"code":      e.ErrCode,
+			"message":   e.Message,
+			"jsonError": err.Error(),
+		})
+		return string(fallbackData)
+	}
+	return string(jsonData)
+}
+
+// jsonRichErrorInput is used to parse the JSON representation of a richError.
+// Inner errors are kept raw so each one can be rehydrated based on its content.
+type jsonRichErrorInput struct {
+	richErrorAlias
+	InnerErrors   []json.RawMessage `json:"innerErrors"`
+	RelatedErrors []struct {
+		Relation string          `json:"relation"`
+		Error    json.RawMessage `json:"error"`
+	} `json:"relatedErrors"`
+	Cause json.RawMessage `json:"cause"`
+}
+
+// UnmarshalJSON reconstructs a richError from its JSON representation.
+// Inner errors with a code are rehydrated as rich errors, all other inner errors become simple errors holding their message.
+func (e *richError) UnmarshalJSON(data []byte) error {
+	var input jsonRichErrorInput
+	if err := json.Unmarshal(data, &input); err != nil {
+		return err
+	}
+	*e = richError(input.richErrorAlias).withNewID()
+	e.InnerErrors = nil
+	for _, rawInnerErr := range input.InnerErrors {
+		innerErr, err := unmarshalInnerError(rawInnerErr)
+		if err != nil {
+			return err
+		}
+		// nil inner errors are skipped like AddError does.
+		if innerErr != nil {
+			e.InnerErrors = append(e.InnerErrors, innerErr)
+		}
+	}
+	e.RelatedErrors = nil
+	for _, rawRelatedErr := range input.RelatedErrors {
+		relatedErr, err := unmarshalInnerError(rawRelatedErr.Error)
+		if err != nil {
+			return err
+		}
+		if relatedErr == nil {
+			continue
+		}
+		e.RelatedErrors = append(e.RelatedErrors, RelatedError{Relation: rawRelatedErr.Relation, Err: relatedErr})
+	}
+	e.Cause = nil
+	if len(input.Cause) > 0 {
+		cause, err := unmarshalInnerError(input.Cause)
+		if err != nil {
+			return err
+		}
+		e.Cause = cause
+	}
+	return nil
+}
+
+// unmarshalInnerError rehydrates an inner, related or cause error. JSON null, which json.Marshal writes for a nil
+// error, returns a nil error instead of an error with an empty message.
+func unmarshalInnerError(data json.RawMessage) (error, error) {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil, nil
+	}
+	var message string
+	if err := json.Unmarshal(data, &message); err == nil {
+		return goerrors.New(message), nil
+	}
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, err
+	}
+	if _, hasCode := probe["code"]; hasCode {
+		var innerErr richError
+		if err := json.Unmarshal(data, &innerErr); err != nil {
+			return nil, err
+		}
+		return innerErr, nil
+	}
+	var plainErr jsonPlainError
+	if err := json.Unmarshal(data, &plainErr); err != nil {
+		return nil, err
+	}
+	return goerrors.New(plainErr.Message), nil
+}
+
+// UnmarshalRichError reconstructs a rich error from JSON produced by json.Marshal or the JSONOutput format.
+func UnmarshalRichError(data []byte) (RichError, error) {
+	var err richError
+	if unmarshalErr := json.Unmarshal(data, &err); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	return err, nil
+}
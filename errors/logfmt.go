@@ -0,0 +1,53 @@
+package errors
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// logfmtOutputString renders the error as a single line of logfmt key=value pairs.
+// Metadata keys are prefixed with "meta." so they can not collide with the core fields. The timestamp uses the
+// timestamp format of the error like the other text output formats.
+func (e richError) logfmtOutputString() string {
+	pairs := []string{
+		logfmtPair("time", e.formatTimestamp(e.OccurredAt)),
+		logfmtPair("code", e.ErrCode),
+		logfmtPair("msg", e.Message),
+	}
+	if e.Source != "" {
+		pairs = append(pairs, logfmtPair("source", e.displayPath(e.Source)))
+	}
+	if e.Line != "" {
+		pairs = append(pairs, logfmtPair("line", e.Line))
+	}
+	if len(e.Tags) > 0 {
+		pairs = append(pairs, logfmtPair("tags", strings.Join(e.Tags, ",")))
+	}
+	metaData := e.redactedMetaData()
+	for _, key := range e.sortedMetaDataKeys() {
+		pairs = append(pairs, logfmtPair(fmt.Sprintf("meta.%s", key), fmt.Sprint(metaData[key])))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// logfmtPair formats a key=value pair, quoting the value when it is empty or contains spaces, quotes or equals signs.
+// Keys can not be quoted in logfmt, so spaces, quotes, equals signs and other characters that would end the key are
+// replaced with underscores.
+func logfmtPair(key, value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\r\n\"=\\") {
+		value = strconv.Quote(value)
+	}
+	return fmt.Sprintf("%s=%s", logfmtKey(key), value)
+}
+
+// logfmtKey replaces the characters of key that are not allowed in a logfmt key with underscores.
+func logfmtKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '=' || r == '"' || r == '\\' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return '_'
+		}
+		return r
+	}, key)
+}
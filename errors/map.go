@@ -0,0 +1,138 @@
+package errors
+
+import "time"
+
+// ToMap returns a representation of the error built only from maps, slices and basic types, using the same keys and
+// values as the JSON output, so it can be fed to any encoder such as msgpack or bson. Timestamps are RFC 3339 strings
+// and retryAfter is a number of nanoseconds like in the JSON output. Inner, cause and related rich errors are converted
+// recursively and other errors become {"message": err.Error()}. Redacted metadata keys are replaced with RedactedValue.
+func (e richError) ToMap() map[string]interface{} {
+	return e.mapValue(newRenderState())
+}
+
+// mapValue returns the map representation of e, or a map with only a message explaining why it was not
+// converted when the maximum render depth is reached or e contains itself.
+func (e richError) mapValue(state *renderState) map[string]interface{} {
+	leave, message := state.enter(e)
+	if leave == nil {
+		return map[string]interface{}{"message": message}
+	}
+	defer leave()
+	errMap := map[string]interface{}{
+		"code":         e.ErrCode,
+		"message":      e.Message,
+		"occurredAt":   e.OccurredAt.Format(time.RFC3339Nano),
+		"severity":     e.GetSeverity().String(),
+		"metaData":     e.redactedMetaData(),
+		"outputFormat": e.getErrorOutputFormat().String(),
+	}
+	if e.Source != "" {
+		errMap["source"] = e.Source
+	}
+	if e.Function != "" {
+		errMap["function"] = e.Function
+	}
+	if e.Line != "" {
+		errMap["line"] = e.Line
+	}
+	// tags, metaData and innerErrors are always present like in the JSON output, where they are null when unset.
+	var tags []string
+	if e.Tags != nil {
+		tags = append(make([]string, 0, len(e.Tags)), e.Tags...)
+	}
+	errMap["tags"] = tags
+	if getIncludeAllTags() {
+		if allTags := e.GetAllTags(); len(allTags) > 0 {
+			errMap["allTags"] = allTags
+		}
+	}
+	if stack := e.stack(); len(stack) > 0 {
+		stackMaps := make([]map[string]interface{}, 0, len(stack))
+		includePointers := getIncludeStackPointers()
+		for _, entry := range stack {
+			stackMap := map[string]interface{}{
+				"depth":    entry.Depth,
+				"file":     entry.File,
+				"function": entry.Function,
+				"line":     entry.Line,
+			}
+			if includePointers {
+				stackMap["entry"] = entry.Entry
+				stackMap["pc"] = entry.PC
+			}
+			stackMaps = append(stackMaps, stackMap)
+		}
+		errMap["stack"] = stackMaps
+	}
+	if e.RetryAfter != nil {
+		errMap["retryAfter"] = int64(*e.RetryAfter)
+	}
+	if e.Action != "" {
+		errMap["action"] = e.Action
+	}
+	if e.Category != "" {
+		errMap["category"] = e.Category
+	}
+	if e.HTTPStatus != 0 {
+		errMap["httpStatus"] = e.HTTPStatus
+	}
+	if e.GRPCCode != 0 {
+		errMap["grpcCode"] = uint32(e.GRPCCode)
+	}
+	if e.Retryable {
+		errMap["retryable"] = true
+	}
+	if e.GoroutineID != 0 {
+		errMap["goroutineId"] = e.GoroutineID
+	}
+	if len(e.Breadcrumbs) > 0 {
+		breadcrumbMaps := make([]map[string]interface{}, 0, len(e.Breadcrumbs))
+		for _, breadcrumb := range e.Breadcrumbs {
+			breadcrumbMap := map[string]interface{}{
+				"timestamp": breadcrumb.Timestamp.Format(time.RFC3339Nano),
+				"message":   breadcrumb.Message,
+			}
+			if breadcrumb.Data != nil {
+				breadcrumbMap["data"] = breadcrumb.Data
+			}
+			breadcrumbMaps = append(breadcrumbMaps, breadcrumbMap)
+		}
+		errMap["breadcrumbs"] = breadcrumbMaps
+	}
+	if e.Cause != nil {
+		errMap["cause"] = errorMapValue(e.inheritRedactedKeys(e.Cause), state)
+	}
+	if len(e.RelatedErrors) > 0 {
+		relatedErrorMaps := make([]map[string]interface{}, 0, len(e.RelatedErrors))
+		for _, relatedErr := range e.RelatedErrors {
+			relatedErrorMaps = append(relatedErrorMaps, map[string]interface{}{
+				"relation": relatedErr.Relation,
+				"error":    errorMapValue(e.inheritRedactedKeys(relatedErr.Err), state),
+			})
+		}
+		errMap["relatedErrors"] = relatedErrorMaps
+	}
+	var innerErrors []map[string]interface{}
+	if e.InnerErrors != nil {
+		innerErrors = make([]map[string]interface{}, 0, len(e.InnerErrors))
+		for _, err := range e.InnerErrors {
+			innerErrors = append(innerErrors, errorMapValue(e.inheritRedactedKeys(err), state))
+		}
+	}
+	errMap["innerErrors"] = innerErrors
+	return errMap
+}
+
+// errorMapValue converts an inner error to its map representation.
+func errorMapValue(err error, state *renderState) map[string]interface{} {
+	switch innerErr := err.(type) {
+	case richError:
+		return innerErr.mapValue(state)
+	case ReadOnlyRichError:
+		return innerErr.ToMap()
+	case nil:
+		return nil
+	default:
+		return map[string]interface{}{"message": innerErr.Error()}
+	}
+}
@@ -0,0 +1,39 @@
+package errors
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+var metricLabelKeys []string
+
+// SetGlobalMetricLabelKeys sets the metadata keys that ToMetricLine may emit as labels.
+// Only keys with low cardinality values such as "component" should be used, since every distinct value becomes a new metric series.
+func SetGlobalMetricLabelKeys(keys ...string) {
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	metricLabelKeys = append([]string(nil), keys...)
+}
+
+func getMetricLabelKeys() []string {
+	outputSettingsMutex.RLock()
+	defer outputSettingsMutex.RUnlock()
+	return metricLabelKeys
+}
+
+// ToMetricLine renders the error as a single line of labels for log based metrics, e.g.
+// richerror code="NotFound" severity="error" component="billing".
+// Only the code, severity and the metadata keys configured with SetGlobalMetricLabelKeys are emitted.
+// The message and all other metadata are intentionally left out because of their high cardinality.
+func (e richError) ToMetricLine() string {
+	var lineBuffer bytes.Buffer
+	lineBuffer.WriteString(fmt.Sprintf("richerror code=%s severity=%s", strconv.Quote(e.ErrCode), strconv.Quote(e.GetSeverity().String())))
+	metaData := e.redactedMetaData()
+	for _, key := range getMetricLabelKeys() {
+		if value, ok := metaData[key]; ok {
+			lineBuffer.WriteString(fmt.Sprintf(" %s=%s", key, strconv.Quote(fmt.Sprint(value))))
+		}
+	}
+	return lineBuffer.String()
+}
@@ -0,0 +1,25 @@
+package errors
+
+var namedOutputFormats = make(map[string]CustomOutputFunc)
+
+// RegisterOutputFormat registers a custom output function under a name so it can be rendered with ToNamedString,
+// e.g. "slack" and "audit" formats for different sinks. Registering a name again replaces its function and
+// registering a nil function removes the name.
+func RegisterOutputFormat(name string, cof CustomOutputFunc) {
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	if cof == nil {
+		delete(namedOutputFormats, name)
+		return
+	}
+	namedOutputFormats[name] = cof
+}
+
+// ToNamedString renders the error with the output function registered under name.
+// If no function is registered under name the error falls back to FullOutputFormatted like ToCustomString.
+func (e richError) ToNamedString(name string) string {
+	outputSettingsMutex.RLock()
+	cof := namedOutputFormats[name]
+	outputSettingsMutex.RUnlock()
+	return e.ToCustomString(cof)
+}
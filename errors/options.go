@@ -0,0 +1,45 @@
+package errors
+
+// RichErrorOption configures a rich error created by NewRichErrorWithOptions.
+type RichErrorOption func(err RichError) RichError
+
+// NewRichErrorWithOptions creates a new rich error and applies the provided options in order.
+func NewRichErrorWithOptions(errCode, message string, opts ...RichErrorOption) RichError {
+	err := NewRichError(errCode, message)
+	for _, opt := range opts {
+		err = opt(err)
+	}
+	return err
+}
+
+// WithStackOption captures the stack of the caller of NewRichErrorWithOptions, skipping stackOffset additional frames.
+func WithStackOption(stackOffset int) RichErrorOption {
+	return func(err RichError) RichError {
+		// Here we add 2 to skip this function and NewRichErrorWithOptions.
+		return err.WithStack(stackOffset + 2)
+	}
+}
+
+func WithTagsOption(tags ...string) RichErrorOption {
+	return func(err RichError) RichError {
+		for _, tag := range tags {
+			err = err.AddTag(tag)
+		}
+		return err
+	}
+}
+
+func WithMetaDataOption(metaData map[string]interface{}) RichErrorOption {
+	return func(err RichError) RichError {
+		for key, value := range metaData {
+			err = err.AddMetaData(key, value)
+		}
+		return err
+	}
+}
+
+func WithErrorsOption(errs ...error) RichErrorOption {
+	return func(err RichError) RichError {
+		return err.WithErrors(errs)
+	}
+}
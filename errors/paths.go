@@ -0,0 +1,54 @@
+package errors
+
+import (
+	"path"
+	"strings"
+)
+
+var useShortPaths bool
+
+// SetUseShortPaths sets whether the text output formats shorten source and stack file paths to their directory and
+// file name, e.g. /home/ci/go/src/github.com/calvine/app/pkg/foo.go becomes pkg/foo.go. The JSON output and
+// GetSource always keep the full path. Errors created with WithShortPaths ignore this setting.
+func SetUseShortPaths(enabled bool) {
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	useShortPaths = enabled
+}
+
+func getUseShortPaths() bool {
+	outputSettingsMutex.RLock()
+	defer outputSettingsMutex.RUnlock()
+	return useShortPaths
+}
+
+// WithShortPaths sets whether the text output formats of this error shorten file paths, overriding SetUseShortPaths.
+func (e richError) WithShortPaths(enabled bool) RichError {
+	e.shortPaths = &enabled
+	return e.withNewID()
+}
+
+// displayPath returns the file path as it should be rendered in the text output formats.
+func (e richError) displayPath(file string) string {
+	var shortPaths bool
+	if e.shortPaths != nil {
+		shortPaths = *e.shortPaths
+	} else {
+		shortPaths = getUseShortPaths()
+	}
+	if !shortPaths {
+		return file
+	}
+	return shortPath(file)
+}
+
+// shortPath returns the last directory and the file name of a path. Stack file paths always use forward slashes.
+func shortPath(file string) string {
+	file = strings.TrimSuffix(file, "/")
+	dir, fileName := path.Split(file)
+	dir = strings.TrimSuffix(dir, "/")
+	if dir == "" {
+		return fileName
+	}
+	return path.Join(path.Base(dir), fileName)
+}
@@ -0,0 +1,59 @@
+package errors
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// PanicValueMetaDataKey is the metadata key RecoverToRichError stores the recovered panic value under.
+const PanicValueMetaDataKey = "panicValue"
+
+// RecoverToRichError converts a value returned by recover into a rich error with the code, the panic value in its
+// metadata and the stack of the panic, starting at the function that panicked rather than the deferred function.
+// A recovered error is also set as the cause so errors.Is and errors.As still match it. It returns nil when
+// recovered is nil, so it can be used directly in a deferred function:
+//
+//	defer func() {
+//		if panicErr := errors.RecoverToRichError("Panic", recover()); panicErr != nil {
+//			err = panicErr
+//		}
+//	}()
+func RecoverToRichError(code string, recovered interface{}) RichError {
+	if recovered == nil {
+		return nil
+	}
+	err := NewRichError(code, fmt.Sprintf("panic: %v", recovered)).
+		AddMetaData(PanicValueMetaDataKey, recovered)
+	if recoveredErr, ok := recovered.(error); ok {
+		err = err.WithCause(recoveredErr)
+	}
+	// the extra frame skips RecoverToRichError so the stack starts at the deferred function like WithStack(0).
+	return err.WithStack(panicStackOffset() + 1)
+}
+
+// panicStackOffset returns the number of frames between the caller of RecoverToRichError and the function that
+// panicked, which are the deferred function, runtime.gopanic and any runtime frames that raised the panic such as
+// runtime.sigpanic. Zero is returned when there is no panic on the stack.
+func panicStackOffset() int {
+	// skip runtime.Callers, panicStackOffset and RecoverToRichError.
+	callerData := make([]uintptr, 64)
+	numFrames := runtime.Callers(3, callerData)
+	frames := runtime.CallersFrames(callerData[:numFrames])
+	offset := 0
+	panicking := false
+	for {
+		frame, more := frames.Next()
+		switch {
+		case frame.Function == "runtime.gopanic":
+			panicking = true
+		case panicking && !strings.HasPrefix(frame.Function, "runtime."):
+			return offset
+		}
+		if !more {
+			break
+		}
+		offset++
+	}
+	return 0
+}
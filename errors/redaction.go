@@ -0,0 +1,102 @@
+package errors
+
+import "strings"
+
+// RedactedValue replaces the value of redacted metadata keys in output.
+const RedactedValue = "[REDACTED]"
+
+var redactedKeys []string
+
+// SetGlobalRedactedKeys sets metadata keys, e.g. "password" or "ssn", that are redacted in the output of every error.
+// Keys are matched case insensitively.
+func SetGlobalRedactedKeys(keys ...string) {
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	redactedKeys = append([]string(nil), keys...)
+}
+
+func getRedactedKeys() []string {
+	outputSettingsMutex.RLock()
+	defer outputSettingsMutex.RUnlock()
+	return redactedKeys
+}
+
+// WithRedactedKeys marks metadata keys whose values are replaced with RedactedValue in all ToString formats, JSON and slog output.
+// The keys also apply to inner rich errors when they are rendered as part of this error.
+// The raw values remain available through GetMetaData and GetMetaDataItem, use RedactMetaData to overwrite them permanently.
+func (e richError) WithRedactedKeys(keys ...string) RichError {
+	e.redactedKeys = append(append(make([]string, 0, len(e.redactedKeys)+len(keys)), e.redactedKeys...), keys...)
+	return e.withNewID()
+}
+
+// RedactMetaData returns a copy of the error with the values of all redacted keys permanently replaced with RedactedValue.
+// This applies recursively to the cause, inner errors and related errors that are rich errors using the redacted keys of this error.
+func (e richError) RedactMetaData() RichError {
+	e.MetaData = e.redactedMetaData()
+	e.Cause = e.redactError(e.Cause)
+	if e.InnerErrors != nil {
+		innerErrors := make([]error, len(e.InnerErrors))
+		for i, err := range e.InnerErrors {
+			innerErrors[i] = e.redactError(err)
+		}
+		e.InnerErrors = innerErrors
+	}
+	if e.RelatedErrors != nil {
+		relatedErrors := make([]RelatedError, len(e.RelatedErrors))
+		for i, relatedErr := range e.RelatedErrors {
+			relatedErrors[i] = RelatedError{Relation: relatedErr.Relation, Err: e.redactError(relatedErr.Err)}
+		}
+		e.RelatedErrors = relatedErrors
+	}
+	return e.withNewID()
+}
+
+// redactError permanently redacts the metadata of err with the redacted keys of this error if it is a richError.
+func (e richError) redactError(err error) error {
+	if innerErr, ok := e.inheritRedactedKeys(err).(richError); ok {
+		return innerErr.RedactMetaData()
+	}
+	return err
+}
+
+// isRedactedKey reports whether key is one of the global redacted keys or the redacted keys of the error.
+func (e richError) isRedactedKey(key string, globalRedactedKeys []string) bool {
+	for _, redactedKey := range globalRedactedKeys {
+		if strings.EqualFold(key, redactedKey) {
+			return true
+		}
+	}
+	for _, redactedKey := range e.redactedKeys {
+		if strings.EqualFold(key, redactedKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactedMetaData returns the metadata to use for output with the values of redacted keys replaced.
+func (e richError) redactedMetaData() map[string]interface{} {
+	globalRedactedKeys := getRedactedKeys()
+	if len(globalRedactedKeys) == 0 && len(e.redactedKeys) == 0 {
+		return e.MetaData
+	}
+	metaData := copyMetaData(e.MetaData)
+	for key := range metaData {
+		if e.isRedactedKey(key, globalRedactedKeys) {
+			metaData[key] = RedactedValue
+		}
+	}
+	return metaData
+}
+
+// inheritRedactedKeys adds the redacted keys of this error to err if it is a richError so redaction applies recursively.
+// The id of err is kept so the render state still detects an error that contains itself.
+func (e richError) inheritRedactedKeys(err error) error {
+	innerErr, ok := err.(richError)
+	if !ok || len(e.redactedKeys) == 0 {
+		return err
+	}
+	inherited := innerErr.WithRedactedKeys(e.redactedKeys...).(richError)
+	inherited.id = innerErr.id
+	return inherited
+}
@@ -0,0 +1,23 @@
+package errors
+
+// RelatedError is an error with a labeled causal relationship to another error, e.g. "caused-by", "triggered-by" or "blocked-on".
+type RelatedError struct {
+	Relation string
+	Err      error
+}
+
+// AddRelatedError records err along with how it relates to this error.
+// Unlike inner errors, related errors are not traversed by Unwrap.
+func (e richError) AddRelatedError(relation string, err error) RichError {
+	if err == nil {
+		return e
+	}
+	relatedErrors := make([]RelatedError, len(e.RelatedErrors), len(e.RelatedErrors)+1)
+	copy(relatedErrors, e.RelatedErrors)
+	e.RelatedErrors = append(relatedErrors, RelatedError{Relation: relation, Err: err})
+	return e.withNewID()
+}
+
+func (e richError) GetRelatedErrors() []RelatedError {
+	return e.RelatedErrors
+}
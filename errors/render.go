@@ -0,0 +1,166 @@
+package errors
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultMaxRenderDepth = 32
+	truncatedMessage      = "... (truncated)"
+	cycleDetectedMessage  = "... (cycle detected)"
+)
+
+// maxPooledBufferSize keeps unusually large buffers, e.g. from errors with huge metadata, from being held by the pool.
+const maxPooledBufferSize = 64 * 1024
+
+var maxRenderDepth = defaultMaxRenderDepth
+
+// bufferPool reuses the buffers text output is rendered into so errors logged on hot paths do not allocate a new buffer on every call.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// getBuffer returns an empty buffer from the pool. It must be returned with putBuffer once its contents have been copied out with String.
+func getBuffer() *bytes.Buffer {
+	buffer := bufferPool.Get().(*bytes.Buffer)
+	buffer.Reset()
+	return buffer
+}
+
+func putBuffer(buffer *bytes.Buffer) {
+	if buffer.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buffer)
+}
+
+// writeSectionHeader writes the header of an output section, preceded by the part seperator unless the previous
+// section already ended with one.
+func writeSectionHeader(messageBuffer *bytes.Buffer, partSeperator, header string) {
+	if !bytes.HasSuffix(messageBuffer.Bytes(), []byte(partSeperator)) {
+		messageBuffer.WriteString(partSeperator)
+	}
+	messageBuffer.WriteString(header)
+}
+
+// SetGlobalMaxRenderDepth limits how deeply nested inner errors are rendered in the full and JSON output formats.
+// Inner errors past the limit are rendered as "... (truncated)". A depth of zero or less restores the default of 32.
+func SetGlobalMaxRenderDepth(depth int) {
+	if depth <= 0 {
+		depth = defaultMaxRenderDepth
+	}
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	maxRenderDepth = depth
+}
+
+func getMaxRenderDepth() int {
+	outputSettingsMutex.RLock()
+	defer outputSettingsMutex.RUnlock()
+	return maxRenderDepth
+}
+
+// renderState tracks the errors on the current rendering path so output always terminates,
+// even when an error contains itself directly or transitively.
+type renderState struct {
+	depth    int
+	maxDepth int
+	visited  map[uint64]struct{}
+}
+
+func newRenderState() *renderState {
+	return &renderState{
+		maxDepth: getMaxRenderDepth(),
+		visited:  make(map[uint64]struct{}),
+	}
+}
+
+// enter records that e is being rendered and returns a function to call once it has been rendered.
+// If e should not be rendered because the maximum depth is reached or it is already being rendered
+// the returned function is nil and the message to render in its place is returned instead.
+func (s *renderState) enter(e richError) (func(), string) {
+	if s.depth >= s.maxDepth {
+		return nil, truncatedMessage
+	}
+	hasID := e.id != 0
+	if hasID {
+		if _, visited := s.visited[e.id]; visited {
+			return nil, cycleDetectedMessage
+		}
+		s.visited[e.id] = struct{}{}
+	}
+	s.depth++
+	return func() {
+		s.depth--
+		if hasID {
+			delete(s.visited, e.id)
+		}
+	}, ""
+}
+
+// lastErrorID is the last id given to an error value by withNewID.
+var lastErrorID uint64
+
+// withNewID returns e with a new id. NewRichError and every builder call it on the value they return, so an id is
+// shared only by copies of the same error value and an error contains itself only if it contains its own id.
+func (e richError) withNewID() richError {
+	e.id = atomic.AddUint64(&lastErrorID, 1)
+	return e
+}
+
+// renderFullOutput renders the full output of e while tracking it in state.
+func (e richError) renderFullOutput(partSeperator, indentString string, recursive bool, state *renderState) string {
+	leave, message := state.enter(e)
+	if leave == nil {
+		return message
+	}
+	defer leave()
+	return e.fullOutputStringWithState(partSeperator, indentString, recursive, state)
+}
+
+// recursiveOutputString renders the inner error e in full, including its stack and inner errors,
+// with each line indented one level deeper than its parent.
+func (e richError) recursiveOutputString(partSeperator, indentString string, state *renderState) string {
+	return indentNestedOutput(e.renderFullOutput(partSeperator, indentString, true, state), partSeperator, indentString)
+}
+
+// indentNestedOutput indents every line of the output of a nested error after the first one level deeper than the
+// line it is written on. The output of each nested error is indented again by its parent, so every line ends up
+// indented by the depth of the error it belongs to.
+func indentNestedOutput(output, partSeperator, indentString string) string {
+	output = strings.TrimSuffix(output, partSeperator)
+	return strings.ReplaceAll(output, partSeperator, fmt.Sprintf("%s%s", partSeperator, strings.Repeat(indentString, 2)))
+}
+
+// errorWithState returns the same output as err.Error() while tracking nested rich errors in state. The multi line
+// full output formats are indented by indentNestedOutput so they line up under the parent error they are nested in.
+// A nil err, which can only be stored by modifying the slice returned by GetErrors, is rendered as <nil> like fmt does.
+func errorWithState(err error, state *renderState) string {
+	if err == nil {
+		return "<nil>"
+	}
+	innerErr, ok := err.(richError)
+	if !ok {
+		return err.Error()
+	}
+	switch innerErr.getErrorOutputFormat() {
+	case FullOutputFormatted:
+		return indentNestedOutput(innerErr.renderFullOutput("\n", "\t", false, state), "\n", "\t")
+	case FullOutputInline:
+		return innerErr.renderFullOutput(" --- ", "", false, state)
+	case FullOutputRecursive:
+		return indentNestedOutput(innerErr.renderFullOutput("\n", "\t", true, state), "\n", "\t")
+	case JSONOutput:
+		return innerErr.jsonOutputStringWithState(jsonIndent, state)
+	case JSONInlineOutput:
+		return innerErr.jsonOutputStringWithState("", state)
+	default:
+		return innerErr.Error()
+	}
+}
@@ -2,19 +2,49 @@ package errors
 
 import (
 	"bytes"
+	goerrors "errors"
 	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"reflect"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	// ErrCodeSanitized is the error code used by SanitizeForClient for errors that are not rich errors.
+	ErrCodeSanitized = "InternalError"
+	// sanitizedErrorMessage is the message used by SanitizeForClient for errors that are not rich errors.
+	sanitizedErrorMessage = "an internal error occurred"
+)
+
+// ErrorCode is a typed error code. Using typed constants for codes prevents mixing them up with other strings.
+type ErrorCode string
+
 type RichErrorOutputFormat int
+
+// GRPCCode is a gRPC status code with the same values as google.golang.org/grpc/codes.Code, e.g. 5 for NotFound.
+// It is defined here so the errors package does not depend on gRPC.
+type GRPCCode uint32
 type CustomOutputFunc func(e ReadOnlyRichError) string
 
 var (
-	customOutputFunction CustomOutputFunc
-	errorOutputFormat    RichErrorOutputFormat = FullOutputFormatted
+	// outputSettingsMutex guards every package level setting, such as clock, customOutputFunction, errorOutputFormat,
+	// namedOutputFormats, redactedKeys and the stack settings in stack.go, because they are read every time an error is
+	// created, built or rendered and may be set concurrently. Settings are only read through getters like
+	// getCustomOutputFunction, except captureGoroutineID which is atomic.
+	outputSettingsMutex    sync.RWMutex
+	customOutputFunction   CustomOutputFunc
+	errorOutputFormat      RichErrorOutputFormat = FullOutputFormatted
+	alwaysEmitStackSection bool
+	includeAllTags         bool
+	clock                  = defaultClock
 )
 
 const (
@@ -25,28 +55,111 @@ const (
 	FullOutputInline
 	ShortDetailedOutput
 	ShortOutput
+	// JSONOutput is the JSON representation of the error, including its stack and inner errors, indented for reading.
+	JSONOutput
+	// FullOutputRecursive is FullOutputFormatted with every inner rich error rendered in full, including its own stack and inner errors.
+	FullOutputRecursive
+	// ColorOutput is FullOutputFormatted with ANSI colored section labels for reading errors in a terminal.
+	ColorOutput
+	// LogfmtOutput is a single line of logfmt key=value pairs for log shippers.
+	LogfmtOutput
+	// JSONInlineOutput is the JSON of JSONOutput on a single line without extra whitespace for log lines.
+	JSONInlineOutput
+	// ShortNoTimeOutput is ShortOutput without the timestamp, e.g. "NotFound - user not found", for showing errors to users.
+	ShortNoTimeOutput
 )
 
+var outputFormatNames = map[RichErrorOutputFormat]string{
+	NotSpecified:        "NotSpecified",
+	CustomOutput:        "CustomOutput",
+	DetailedOutput:      "DetailedOutput",
+	FullOutputFormatted: "FullOutputFormatted",
+	FullOutputInline:    "FullOutputInline",
+	ShortDetailedOutput: "ShortDetailedOutput",
+	ShortOutput:         "ShortOutput",
+	JSONOutput:          "JSONOutput",
+	FullOutputRecursive: "FullOutputRecursive",
+	ColorOutput:         "ColorOutput",
+	LogfmtOutput:        "LogfmtOutput",
+	JSONInlineOutput:    "JSONInlineOutput",
+	ShortNoTimeOutput:   "ShortNoTimeOutput",
+}
+
+func (f RichErrorOutputFormat) String() string {
+	if name, ok := outputFormatNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("RichErrorOutputFormat(%d)", int(f))
+}
+
+// MarshalText renders the output format as the name of its constant, e.g. FullOutputFormatted,
+// so output formats can be named in JSON and YAML configuration.
+func (f RichErrorOutputFormat) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText parses the name of an output format constant. Names are matched case insensitively.
+func (f *RichErrorOutputFormat) UnmarshalText(text []byte) error {
+	name := strings.ToLower(string(text))
+	for format, formatName := range outputFormatNames {
+		if strings.ToLower(formatName) == name {
+			*f = format
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format: %s", text)
+}
+
 type ReadOnlyRichError interface {
 	GetErrorCode() string
 	GetErrorMessage() string
 	GetStack() []callStackEntry
+	GetStackStrings() []string
 	GetSource() string
 	GetFunction() string
 	GetLineNumber() string
+	GetOccurredAt() time.Time
 	GetTags() []string
+	GetAllTags() []string
+	HasTag(tag string) bool
+	Equal(other ReadOnlyRichError) bool
 	GetMetaData() map[string]interface{}
 	GetMetaDataItem(key string) (interface{}, bool)
 	GetErrors() []error
+	GetInnerRichErrors() []ReadOnlyRichError
+	GetCause() error
+	GetRootError() error
+	Walk(fn func(err ReadOnlyRichError, depth int) bool)
+	GetRawPayload() ([]byte, bool)
+	GetRetryAfter() (time.Duration, bool)
+	GetAction() (string, bool)
+	GetCategory() (string, bool)
+	GetSeverity() Severity
+	MoreSevereThan(other ReadOnlyRichError) bool
+	GetHTTPStatus() (int, bool)
+	GetGRPCCode() (GRPCCode, bool)
+	IsRetryable() bool
+	GetGoroutineID() (uint64, bool)
+	GetBreadcrumbs() []Breadcrumb
+	GetRelatedErrors() []RelatedError
 	HasStack() bool
+	HasValidPCs() bool
 	ToString(format RichErrorOutputFormat) string
 	ToCustomString(cof CustomOutputFunc) string
+	ToNamedString(name string) string
+	GetEffectiveOutputFormat() RichErrorOutputFormat
+	DebugString() string
+	CanonicalString() string
+	ToMetricLine() string
+	ToMap() map[string]interface{}
 
 	error
 }
 
 type RichError interface {
 	WithStack(stackOffset int) RichError
+	WithStackDepth(stackOffset, maxDepth int) RichError
+	WithShortPaths(enabled bool) RichError
 	WithMetaData(metaData map[string]interface{}) RichError
 	WithErrors(errs []error) RichError
 	WithTags(tags []string) RichError
@@ -54,8 +167,27 @@ type RichError interface {
 	AddFunction(function string) RichError
 	AddLineNumber(lineNumber string) RichError
 	AddMetaData(key string, value interface{}) RichError
+	RemoveMetaData(key string) RichError
 	AddError(err error) RichError
+	WithCause(err error) RichError
 	AddTag(tag string) RichError
+	WithRawPayload(payload []byte, maxBytes int) RichError
+	WithRetryAfter(retryAfter time.Duration) RichError
+	ReSymbolize() RichError
+	PromoteInnerTags(prefix string) RichError
+	WithAction(action string) RichError
+	WithCategory(category string) RichError
+	WithSeverity(severity Severity) RichError
+	WithHTTPStatus(status int) RichError
+	WithGRPCCode(code GRPCCode) RichError
+	WithRetryable(retryable bool) RichError
+	AddBreadcrumb(message string, data map[string]interface{}) RichError
+	Clone() RichError
+	AddRelatedError(relation string, err error) RichError
+	WithRedactedKeys(keys ...string) RichError
+	RedactMetaData() RichError
+	SetOutputFormat(format RichErrorOutputFormat) RichError
+	SetTimestampFormat(layout string) RichError
 
 	ReadOnlyRichError
 }
@@ -84,116 +216,506 @@ type richError struct {
 	Stack       []callStackEntry       `json:"stack,omitempty"`
 	InnerErrors []error                `json:"innerErrors"`
 	MetaData    map[string]interface{} `json:"metaData"`
+	RetryAfter  *time.Duration         `json:"retryAfter,omitempty"`
+	Action      string                 `json:"action,omitempty"`
+	Category    string                 `json:"category,omitempty"`
+	Severity    Severity               `json:"severity"`
+	HTTPStatus  int                    `json:"httpStatus,omitempty"`
+	GRPCCode    GRPCCode               `json:"grpcCode,omitempty"`
+	Retryable   bool                   `json:"retryable,omitempty"`
+	GoroutineID uint64                 `json:"goroutineId,omitempty"`
+	Breadcrumbs []Breadcrumb           `json:"breadcrumbs,omitempty"`
+	// RelatedErrors is serialized through jsonRichError because errors can not be marshaled directly.
+	RelatedErrors []RelatedError `json:"-"`
+	// Cause is the primary cause set by WithCause. It is serialized through jsonRichError like RelatedErrors.
+	Cause error `json:"-"`
+	// rawPayload is unexported so it is never included in JSON or the standard output formats.
+	rawPayload       []byte
+	rawPayloadLength int
+	redactedKeys     []string
+	// lazyStack holds the program counters captured by WithStack until they are resolved into Stack entries.
+	lazyStack *lazyStack
+	// shortPaths overrides the global short paths setting when it is not nil.
+	shortPaths *bool
+	// outputFormat overrides the global output format used by Error() when it is not NotSpecified.
+	outputFormat RichErrorOutputFormat
+	// timestampFormat overrides the global timestamp format of the text outputs when it is not empty.
+	timestampFormat string
+	// id identifies the error value for cycle detection. It is zero for errors that were not built by this package.
+	id uint64
 }
 
 func SetCustomOutputFunction(cof CustomOutputFunc) {
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
 	customOutputFunction = cof
 }
 
 func SetErrorOutputFormat(format RichErrorOutputFormat) {
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
 	errorOutputFormat = format
 }
 
+// HasCustomOutputFunction reports whether a custom output function is set for the CustomOutput format.
+// Without one CustomOutput falls back to FullOutputFormatted.
+func HasCustomOutputFunction() bool {
+	return getCustomOutputFunction() != nil
+}
+
+func getCustomOutputFunction() CustomOutputFunc {
+	outputSettingsMutex.RLock()
+	defer outputSettingsMutex.RUnlock()
+	return customOutputFunction
+}
+
+// SetGlobalAlwaysEmitStackSection controls whether the full output formats include a STACK section
+// even when no stack was captured, which keeps the shape of the output stable for parsers.
+func SetGlobalAlwaysEmitStackSection(alwaysEmit bool) {
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	alwaysEmitStackSection = alwaysEmit
+}
+
+func getAlwaysEmitStackSection() bool {
+	outputSettingsMutex.RLock()
+	defer outputSettingsMutex.RUnlock()
+	return alwaysEmitStackSection
+}
+
+// SetGlobalIncludeAllTags controls whether the JSON output, ToMap and LogValue include an allTags field with the
+// tags of the error and all of its inner rich errors from GetAllTags, so log queries can match any tag in the chain.
+func SetGlobalIncludeAllTags(include bool) {
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	includeAllTags = include
+}
+
+func getIncludeAllTags() bool {
+	outputSettingsMutex.RLock()
+	defer outputSettingsMutex.RUnlock()
+	return includeAllTags
+}
+
+// SetClock replaces the function used to timestamp errors and breadcrumbs, which allows tests to freeze time.
+// This only affects errors constructed after it is called. Passing nil restores the default clock.
+func SetClock(now func() time.Time) {
+	if now == nil {
+		now = defaultClock
+	}
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	clock = now
+}
+
+// currentTime returns the current time from the clock set with SetClock.
+func currentTime() time.Time {
+	outputSettingsMutex.RLock()
+	now := clock
+	outputSettingsMutex.RUnlock()
+	return now()
+}
+
+func defaultClock() time.Time {
+	return time.Now().UTC()
+}
+
 func NewRichError(errCode, message string) RichError {
-	occurredAt := time.Now().UTC()
+	occurredAt := currentTime()
 	err := richError{
 		ErrCode:    errCode,
 		Message:    message,
 		OccurredAt: occurredAt,
 	}
-	return err
+	return err.withNewID()
+
+}
+
+// Merge creates a rich error whose inner errors are errs, skipping nil errors, so a batch operation can return a single
+// error that keeps every underlying failure. The tags of inner rich errors are added to the merged error without
+// duplicates, and the stack of the caller of Merge is captured. errors.Is matches any of the inner errors.
+func Merge(code, message string, errs ...error) RichError {
+	mergedErr := NewRichError(code, message)
+	seenTags := make(map[string]bool)
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		mergedErr = mergedErr.AddError(err)
+		innerErr, ok := err.(ReadOnlyRichError)
+		if !ok {
+			continue
+		}
+		for _, tag := range innerErr.GetTags() {
+			if !seenTags[tag] {
+				seenTags[tag] = true
+				mergedErr = mergedErr.AddTag(tag)
+			}
+		}
+	}
+	return mergedErr.WithStack(1)
+}
+
+// Wrap creates a rich error with err as its inner error and the stack of the caller of Wrap. It returns nil when err
+// is nil, so return Wrap(doThing(), code, message) never returns a non-nil error that wraps nothing.
+func Wrap(err error, code, message string) RichError {
+	if err == nil {
+		return nil
+	}
+	return NewRichError(code, message).AddError(err).WithStack(1)
+}
+
+// NewRichErrorTyped creates a new rich error from a typed ErrorCode.
+func NewRichErrorTyped(code ErrorCode, message string) RichError {
+	return NewRichError(string(code), message)
+}
+
+// New creates a new rich error from any string kinded code type so callers using their own code types get compile time safety.
+func New[T ~string](code T, message string) RichError {
+	return NewRichError(string(code), message)
+}
+
+// SanitizeForClient returns a view of err that is safe to expose to clients.
+// If err is or wraps a rich error, the result keeps its code, message, occurred at time and any metadata keys in allowedMeta.
+// The stack, source location, tags, inner errors and all other metadata are dropped.
+// Any other error is replaced by a generic error with the ErrCodeSanitized code. A nil err returns nil.
+func SanitizeForClient(err error, allowedMeta []string) ReadOnlyRichError {
+	if err == nil {
+		return nil
+	}
+	var richErr ReadOnlyRichError
+	if !goerrors.As(err, &richErr) {
+		return NewRichError(ErrCodeSanitized, sanitizedErrorMessage)
+	}
+	sanitizedErr := richError{
+		ErrCode:    richErr.GetErrorCode(),
+		Message:    richErr.GetErrorMessage(),
+		OccurredAt: richErr.GetOccurredAt(),
+	}
+	for _, key := range allowedMeta {
+		if value, ok := richErr.GetMetaDataItem(key); ok {
+			if sanitizedErr.MetaData == nil {
+				sanitizedErr.MetaData = make(map[string]interface{})
+			}
+			sanitizedErr.MetaData[key] = value
+		}
+	}
+	return sanitizedErr.withNewID()
+}
 
+// HTTPStatusFromError walks the error chain using Unwrap and returns the first HTTP status associated with a rich error.
+// If no status is found http.StatusInternalServerError is returned.
+func HTTPStatusFromError(err error) int {
+	for err != nil {
+		if richErr, ok := err.(ReadOnlyRichError); ok {
+			if status, ok := richErr.GetHTTPStatus(); ok {
+				return status
+			}
+		}
+		err = goerrors.Unwrap(err)
+	}
+	return http.StatusInternalServerError
+}
+
+// IsRetryable walks the error chain using Unwrap, along with the cause and inner errors of every rich error in it,
+// and reports whether any rich error is marked retryable.
+func IsRetryable(err error) bool {
+	for err != nil {
+		if richErr, ok := err.(ReadOnlyRichError); ok {
+			retryable := false
+			richErr.Walk(func(walkedErr ReadOnlyRichError, depth int) bool {
+				retryable = walkedErr.IsRetryable()
+				return !retryable
+			})
+			if retryable {
+				return true
+			}
+		}
+		err = goerrors.Unwrap(err)
+	}
+	return false
 }
 
+// NewRichErrorWithStack creates a new error with the stack of its caller, skipping stackOffset additional frames.
 func NewRichErrorWithStack(errCode, message string, stackOffset int) RichError {
-	err := NewRichError(errCode, message).WithStack(stackOffset)
+	// the extra frame skips NewRichErrorWithStack so the stack starts at the caller like WithStack(0).
+	err := NewRichError(errCode, message).WithStack(stackOffset + 1)
 	return err
 }
 
 func (e richError) WithStack(stackOffset int) RichError {
-	baseStackOffset := 2
-	// Here we initialize the slice to 10 because the runtime.Callers
-	// function will not grow the slice as needed.
-	var callerData []uintptr = make([]uintptr, 10)
-	// Here we use 2 to remove the runtime.Callers call
-	// and the call to the RichError.WithStack call.
-	// This should leave only the relevant stack pieces
-	numFrames := runtime.Callers(baseStackOffset+stackOffset, callerData)
-	data := runtime.CallersFrames(callerData)
-	for i := 0; i < numFrames; i++ {
-		nextFrame, _ := data.Next()
-		if i == 0 {
-			source := nextFrame.File
-
-			functionName := nextFrame.Function
-			if len(functionName) > 0 {
-				functionNameLastIndex := strings.LastIndex(functionName, ".")
-				functionName = functionName[functionNameLastIndex+1:]
-			}
-			e.Source = source
-			e.Function = functionName
-			e.Line = strconv.Itoa(nextFrame.Line)
+	return e.captureStack(stackOffset, getMaxStackDepth())
+}
+
+// WithStackDepth captures the stack like WithStack but retains at most maxDepth frames. When frames are dropped a final
+// entry records how many were omitted. A maxDepth of zero or less retains every frame.
+func (e richError) WithStackDepth(stackOffset, maxDepth int) RichError {
+	return e.captureStack(stackOffset, maxDepth)
+}
+
+func (e *richError) setLocation(source, functionName string, line int) {
+	if len(functionName) > 0 {
+		functionNameLastIndex := strings.LastIndex(functionName, ".")
+		functionName = functionName[functionNameLastIndex+1:]
+	}
+	e.Source = source
+	e.Function = functionName
+	e.Line = strconv.Itoa(line)
+}
+
+// ReSymbolize refreshes the file, function and line of each stack entry from its program counter.
+// This is only done when HasValidPCs reports true, otherwise the error is returned unchanged.
+func (e richError) ReSymbolize() RichError {
+	if !e.HasValidPCs() {
+		return e
+	}
+	currentStack := e.stack()
+	stack := make([]callStackEntry, len(currentStack))
+	for i, entry := range currentStack {
+		if entry.isOmittedFrames() {
+			stack[i] = entry
+			continue
 		}
-		callStackEntry := callStackEntry{
-			Depth:    i,
-			Entry:    nextFrame.Entry,
-			File:     nextFrame.File,
-			Function: nextFrame.Function,
-			Line:     nextFrame.Line,
-			PC:       nextFrame.PC,
+		fn := runtime.FuncForPC(entry.PC)
+		file, line := fn.FileLine(entry.PC)
+		stack[i] = callStackEntry{
+			Depth:    entry.Depth,
+			Entry:    fn.Entry(),
+			File:     file,
+			Function: fn.Name(),
+			Line:     line,
+			PC:       entry.PC,
 		}
-		e.Stack = append(e.Stack, callStackEntry)
 	}
-
-	return e
+	e.Stack = stack
+	e.lazyStack = nil
+	e.setLocation(stack[0].File, stack[0].Function, stack[0].Line)
+	return e.withNewID()
 }
 
+// WithMetaData copies metaData into a new map so later changes to either map do not affect the other.
 func (e richError) WithMetaData(metaData map[string]interface{}) RichError {
-	e.MetaData = metaData
-	return e
+	e.MetaData = copyMetaData(metaData)
+	return e.withNewID()
 }
 
+// WithErrors appends errs to the inner errors, skipping nil errors.
 func (e richError) WithErrors(errs []error) RichError {
-	e.InnerErrors = append(e.InnerErrors, errs...)
-	return e
+	innerErrors := e.InnerErrors[:len(e.InnerErrors):len(e.InnerErrors)]
+	for _, err := range errs {
+		if err != nil {
+			innerErrors = append(innerErrors, err)
+		}
+	}
+	e.InnerErrors = innerErrors
+	return e.withNewID()
 }
 
 func (e richError) WithTags(tags []string) RichError {
 	e.Tags = tags
-	return e
+	return e.withNewID()
 }
 
 func (e richError) AddSource(source string) RichError {
 	e.Source = source
-	return e
+	return e.withNewID()
 }
 
 func (e richError) AddFunction(function string) RichError {
 	e.Function = function
-	return e
+	return e.withNewID()
 }
 
 func (e richError) AddLineNumber(lineNumber string) RichError {
 	e.Line = lineNumber
-	return e
+	return e.withNewID()
 }
 
+// AddMetaData clones the existing metadata before adding the key so errors derived from the same base do not share a map.
 func (e richError) AddMetaData(key string, value interface{}) RichError {
-	if e.MetaData == nil {
-		e.MetaData = make(map[string]interface{})
+	metaData := copyMetaData(e.MetaData)
+	if metaData == nil {
+		metaData = make(map[string]interface{})
+	}
+	metaData[key] = value
+	e.MetaData = metaData
+	return e.withNewID()
+}
+
+// RemoveMetaData returns a copy of the error without the metadata key. Removing a missing key is a no-op.
+func (e richError) RemoveMetaData(key string) RichError {
+	if _, ok := e.MetaData[key]; !ok {
+		return e
 	}
-	e.MetaData[key] = value
-	return e
+	metaData := copyMetaData(e.MetaData)
+	delete(metaData, key)
+	e.MetaData = metaData
+	return e.withNewID()
 }
 
+// AddError appends err to the inner errors. A nil err is skipped. If err is the error itself, or already contains it as
+// a cause or inner error, it is also skipped so the error can never contain itself.
 func (e richError) AddError(err error) RichError {
-	e.InnerErrors = append(e.InnerErrors, err)
-	return e
+	if err == nil || e.containedBy(err) {
+		return e
+	}
+	// the inner errors are clipped so appending never writes into a backing array shared with a copy of the error.
+	e.InnerErrors = append(e.InnerErrors[:len(e.InnerErrors):len(e.InnerErrors)], err)
+	return e.withNewID()
+}
+
+// containedBy reports whether err is e or contains e as a cause or inner error at any depth.
+func (e richError) containedBy(err error) bool {
+	richErr, ok := err.(ReadOnlyRichError)
+	if e.id == 0 || !ok {
+		return false
+	}
+	contained := false
+	richErr.Walk(func(walkedErr ReadOnlyRichError, depth int) bool {
+		if walkedRichErr, ok := walkedErr.(richError); ok {
+			contained = walkedRichErr.id == e.id
+		}
+		return !contained
+	})
+	return contained
+}
+
+// WithCause sets the primary cause of the error, which Unwrap returns before any inner errors, so wrapping a single
+// error lines up with fmt.Errorf("%w"). The cause is kept separately from the inner errors added with AddError.
+func (e richError) WithCause(err error) RichError {
+	e.Cause = err
+	return e.withNewID()
 }
 
 func (e richError) AddTag(tag string) RichError {
 	e.Tags = append(e.Tags, tag)
-	return e
+	return e.withNewID()
+}
+
+// Clone returns a deep copy of the error so it can safely be used as a prototype, for example a package level
+// error that each call site augments with call specific metadata. Inner errors themselves are not cloned.
+func (e richError) Clone() RichError {
+	if stack := e.stack(); stack != nil {
+		e.Stack = append(make([]callStackEntry, 0, len(stack)), stack...)
+		e.lazyStack = nil
+	}
+	if e.Tags != nil {
+		e.Tags = append(make([]string, 0, len(e.Tags)), e.Tags...)
+	}
+	if e.InnerErrors != nil {
+		e.InnerErrors = append(make([]error, 0, len(e.InnerErrors)), e.InnerErrors...)
+	}
+	e.MetaData = copyMetaData(e.MetaData)
+	if e.Breadcrumbs != nil {
+		breadcrumbs := make([]Breadcrumb, len(e.Breadcrumbs))
+		for i, breadcrumb := range e.Breadcrumbs {
+			breadcrumb.Data = copyMetaData(breadcrumb.Data)
+			breadcrumbs[i] = breadcrumb
+		}
+		e.Breadcrumbs = breadcrumbs
+	}
+	if e.RelatedErrors != nil {
+		e.RelatedErrors = append(make([]RelatedError, 0, len(e.RelatedErrors)), e.RelatedErrors...)
+	}
+	if e.RetryAfter != nil {
+		retryAfter := *e.RetryAfter
+		e.RetryAfter = &retryAfter
+	}
+	if e.redactedKeys != nil {
+		e.redactedKeys = append(make([]string, 0, len(e.redactedKeys)), e.redactedKeys...)
+	}
+	if e.rawPayload != nil {
+		e.rawPayload = append(make([]byte, 0, len(e.rawPayload)), e.rawPayload...)
+	}
+	return e.withNewID()
+}
+
+// PromoteInnerTags copies the tags of each inner rich error onto this error with prefix prepended, e.g. "inner:retryable".
+// Tags that are already present on this error are not added again and the inner errors are not modified.
+func (e richError) PromoteInnerTags(prefix string) RichError {
+	tags := make([]string, len(e.Tags))
+	copy(tags, e.Tags)
+	existingTags := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		existingTags[tag] = true
+	}
+	for _, err := range e.InnerErrors {
+		innerErr, ok := err.(ReadOnlyRichError)
+		if !ok {
+			continue
+		}
+		for _, tag := range innerErr.GetTags() {
+			promotedTag := prefix + tag
+			if !existingTags[promotedTag] {
+				existingTags[promotedTag] = true
+				tags = append(tags, promotedTag)
+			}
+		}
+	}
+	e.Tags = tags
+	return e.withNewID()
+}
+
+// WithRawPayload stores a copy of at most maxBytes of payload on the error. If maxBytes is not positive the whole payload is kept.
+// The payload is only rendered by DebugString.
+func (e richError) WithRawPayload(payload []byte, maxBytes int) RichError {
+	e.rawPayloadLength = len(payload)
+	if maxBytes > 0 && len(payload) > maxBytes {
+		payload = payload[:maxBytes]
+	}
+	e.rawPayload = make([]byte, len(payload))
+	copy(e.rawPayload, payload)
+	return e.withNewID()
+}
+
+// WithRetryAfter records how long a client should wait before retrying, for example to populate a Retry-After header.
+func (e richError) WithRetryAfter(retryAfter time.Duration) RichError {
+	e.RetryAfter = &retryAfter
+	return e.withNewID()
+}
+
+// WithAction records the name of the user action that triggered the error, e.g. "checkout" or "upload_avatar".
+func (e richError) WithAction(action string) RichError {
+	e.Action = action
+	return e.withNewID()
+}
+
+// WithCategory sets a coarse classification of the error, e.g. "validation", "io" or "auth", so errors with many
+// specific codes can be grouped together on dashboards.
+func (e richError) WithCategory(category string) RichError {
+	e.Category = category
+	return e.withNewID()
+}
+
+func (e richError) WithSeverity(severity Severity) RichError {
+	e.Severity = severity
+	return e.withNewID()
+}
+
+// SetOutputFormat sets the format Error() renders this error with, overriding the global output format.
+// Passing NotSpecified makes the error use the global output format again.
+func (e richError) SetOutputFormat(format RichErrorOutputFormat) RichError {
+	e.outputFormat = format
+	return e.withNewID()
+}
+
+// WithGRPCCode associates the gRPC status code a service should respond with for this error.
+// The github.com/calvine/richerror/grpc module converts rich errors to gRPC statuses using it.
+func (e richError) WithGRPCCode(code GRPCCode) RichError {
+	e.GRPCCode = code
+	return e.withNewID()
+}
+
+// WithRetryable marks whether the operation that failed with this error can be retried, e.g. by backoff logic.
+func (e richError) WithRetryable(retryable bool) RichError {
+	e.Retryable = retryable
+	return e.withNewID()
+}
+
+// WithHTTPStatus associates the HTTP status code a handler should respond with for this error.
+func (e richError) WithHTTPStatus(status int) RichError {
+	e.HTTPStatus = status
+	return e.withNewID()
 }
 
 func (e richError) GetErrorCode() string {
@@ -205,7 +727,21 @@ func (e richError) GetErrorMessage() string {
 }
 
 func (e richError) GetStack() []callStackEntry {
-	return e.Stack
+	return e.stack()
+}
+
+// GetStackStrings returns each frame of the stack formatted like the text output formats, for logging frameworks that
+// accept stack traces as a list of lines. File paths are always full paths like GetStack.
+func (e richError) GetStackStrings() []string {
+	stack := e.stack()
+	if len(stack) == 0 {
+		return nil
+	}
+	stackStrings := make([]string, 0, len(stack))
+	for _, frame := range stack {
+		stackStrings = append(stackStrings, formatStackFrame(frame))
+	}
+	return stackStrings
 }
 
 func (e richError) GetSource() string {
@@ -220,6 +756,10 @@ func (e richError) GetLineNumber() string {
 	return e.Line
 }
 
+func (e richError) GetOccurredAt() time.Time {
+	return e.OccurredAt
+}
+
 func (e richError) GetMetaData() map[string]interface{} {
 	return e.MetaData
 }
@@ -228,6 +768,35 @@ func (e richError) GetTags() []string {
 	return e.Tags
 }
 
+// GetAllTags returns the tags of this error followed by the tags of its cause and inner rich errors at any depth,
+// without duplicates, so errors can be filtered by any tag in the chain.
+func (e richError) GetAllTags() []string {
+	var allTags []string
+	seenTags := make(map[string]bool)
+	e.Walk(func(err ReadOnlyRichError, depth int) bool {
+		for _, tag := range err.GetTags() {
+			if !seenTags[tag] {
+				seenTags[tag] = true
+				allTags = append(allTags, tag)
+			}
+		}
+		return true
+	})
+	return allTags
+}
+
+// HasTag reports whether the error has the given tag. Tags are compared case insensitively
+// and surrounding whitespace is ignored, the same way the generator matches tags.
+func (e richError) HasTag(tag string) bool {
+	tag = strings.TrimSpace(tag)
+	for _, errTag := range e.Tags {
+		if strings.EqualFold(strings.TrimSpace(errTag), tag) {
+			return true
+		}
+	}
+	return false
+}
+
 func (e richError) GetMetaDataItem(key string) (interface{}, bool) {
 	if e.MetaData == nil {
 		return nil, false
@@ -240,78 +809,443 @@ func (e richError) GetErrors() []error {
 	return e.InnerErrors
 }
 
+// GetInnerRichErrors returns the inner errors that are rich errors in the order they were added. Other inner errors are skipped.
+func (e richError) GetInnerRichErrors() []ReadOnlyRichError {
+	var richErrs []ReadOnlyRichError
+	for _, err := range e.InnerErrors {
+		if richErr, ok := err.(ReadOnlyRichError); ok {
+			richErrs = append(richErrs, richErr)
+		}
+	}
+	return richErrs
+}
+
+// GetCause returns the primary cause set by WithCause, or nil if no cause was set.
+func (e richError) GetCause() error {
+	return e.Cause
+}
+
+func (e richError) GetRawPayload() ([]byte, bool) {
+	if e.rawPayload == nil {
+		return nil, false
+	}
+	payload := make([]byte, len(e.rawPayload))
+	copy(payload, e.rawPayload)
+	return payload, true
+}
+
+func (e richError) GetRetryAfter() (time.Duration, bool) {
+	if e.RetryAfter == nil {
+		return 0, false
+	}
+	return *e.RetryAfter, true
+}
+
+func (e richError) GetAction() (string, bool) {
+	return e.Action, e.Action != ""
+}
+
+// GetCategory returns the category set by WithCategory. Errors without a category report false.
+func (e richError) GetCategory() (string, bool) {
+	return e.Category, e.Category != ""
+}
+
+// GetSeverity returns the severity of the error, which is SeverityError when no severity was set.
+func (e richError) GetSeverity() Severity {
+	if e.Severity == SeverityNotSpecified {
+		return SeverityError
+	}
+	return e.Severity
+}
+
+func (e richError) GetHTTPStatus() (int, bool) {
+	return e.HTTPStatus, e.HTTPStatus != 0
+}
+
+// GetGRPCCode returns the gRPC status code associated with the error. Errors without a code report false.
+func (e richError) GetGRPCCode() (GRPCCode, bool) {
+	return e.GRPCCode, e.GRPCCode != 0
+}
+
+// IsRetryable reports whether the error was marked retryable with WithRetryable.
+// Use the IsRetryable function to also check the errors it wraps.
+func (e richError) IsRetryable() bool {
+	return e.Retryable
+}
+
+// Unwrap returns the cause set by WithCause, or the first inner error when there is no cause, so errors.Is and
+// errors.As can traverse into a richError. Go only allows one Unwrap method per type, and the single error form is
+// kept so errors.Unwrap, GetRootError and HTTPStatusFromError follow a single chain of causes. errors.Is and errors.As
+// still reach the remaining inner errors through the Is and As methods, and every inner error is returned by GetErrors.
+func (e richError) Unwrap() error {
+	if e.Cause != nil {
+		return e.Cause
+	}
+	if len(e.InnerErrors) == 0 {
+		return nil
+	}
+	return e.InnerErrors[0]
+}
+
+// Walk performs a depth first traversal of the error and every inner error that is a ReadOnlyRichError,
+// calling fn with each error and its nesting depth starting at 0. The traversal stops as soon as fn returns false.
+// The cause is visited before the inner errors and inner errors that are not rich errors are skipped.
+func (e richError) Walk(fn func(err ReadOnlyRichError, depth int) bool) {
+	walkRichError(e, 0, make(map[uint64]struct{}), fn)
+}
+
+func walkRichError(err ReadOnlyRichError, depth int, visited map[uint64]struct{}, fn func(err ReadOnlyRichError, depth int) bool) bool {
+	if richErr, ok := err.(richError); ok && richErr.id != 0 {
+		if _, seen := visited[richErr.id]; seen {
+			// the error contains itself so its inner errors have already been visited.
+			return true
+		}
+		visited[richErr.id] = struct{}{}
+		defer delete(visited, richErr.id)
+	}
+	if !fn(err, depth) {
+		return false
+	}
+	innerErrs := err.GetErrors()
+	if cause := err.GetCause(); cause != nil {
+		innerErrs = append([]error{cause}, innerErrs...)
+	}
+	for _, innerErr := range innerErrs {
+		innerRichErr, ok := innerErr.(ReadOnlyRichError)
+		if !ok {
+			continue
+		}
+		if !walkRichError(innerRichErr, depth+1, visited, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// GetRootError returns the innermost cause of the error by following Unwrap until it reaches an error
+// that does not wrap another. If the error has no inner errors it returns itself.
+func (e richError) GetRootError() error {
+	var rootErr error = e
+	visited := make(map[uint64]struct{})
+	for {
+		if richErr, ok := rootErr.(richError); ok && richErr.id != 0 {
+			if _, seen := visited[richErr.id]; seen {
+				// the error contains itself so there is no innermost cause.
+				return rootErr
+			}
+			visited[richErr.id] = struct{}{}
+		}
+		innerErr := goerrors.Unwrap(rootErr)
+		if innerErr == nil {
+			return rootErr
+		}
+		rootErr = innerErr
+	}
+}
+
+// Is reports whether target is, or wraps, a ReadOnlyRichError with the same error code as e.
+// Only the error code is compared; message, tags and metadata are intentionally ignored so that
+// errors.Is(err, NewRichError("NotFound", "")) matches any error with the NotFound code.
+// Because Unwrap only returns the first inner error, the remaining inner errors are also checked
+// with errors.Is so every cause of an error created by Merge can be matched.
+func (e richError) Is(target error) bool {
+	var richTarget ReadOnlyRichError
+	if goerrors.As(target, &richTarget) && richTarget.GetErrorCode() == e.ErrCode {
+		return true
+	}
+	for _, err := range e.innerErrorsNotUnwrapped() {
+		if goerrors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the inner errors that Unwrap does not return that matches target, so errors.As can match every
+// cause of an error created by Merge like errors.Is does. The error returned by Unwrap is left to errors.As so each level
+// of a nested chain is only traversed once.
+func (e richError) As(target interface{}) bool {
+	for _, err := range e.innerErrorsNotUnwrapped() {
+		if goerrors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// innerErrorsNotUnwrapped returns the inner errors errors.Is and errors.As can not reach through Unwrap.
+func (e richError) innerErrorsNotUnwrapped() []error {
+	if e.Cause != nil {
+		// Unwrap returns the cause instead of the first inner error.
+		return e.InnerErrors
+	}
+	if len(e.InnerErrors) == 0 {
+		return nil
+	}
+	return e.InnerErrors[1:]
+}
+
+// Equal reports whether other has the same error code, message, tags and metadata as e. Tags are compared ignoring
+// their order and metadata values are compared with reflect.DeepEqual, with nil and empty tags and metadata treated
+// as equal. Every other field, including the occurred at time, stack, source, function, line, inner errors and
+// severity, is ignored so tests can assert a specific error was returned without depending on when or where it happened.
+func (e richError) Equal(other ReadOnlyRichError) bool {
+	if other == nil || e.ErrCode != other.GetErrorCode() || e.Message != other.GetErrorMessage() {
+		return false
+	}
+	otherTags := other.GetTags()
+	if len(e.Tags) != len(otherTags) {
+		return false
+	}
+	tags := append(make([]string, 0, len(e.Tags)), e.Tags...)
+	otherTags = append(make([]string, 0, len(otherTags)), otherTags...)
+	sort.Strings(tags)
+	sort.Strings(otherTags)
+	for i := range tags {
+		if tags[i] != otherTags[i] {
+			return false
+		}
+	}
+	otherMetaData := other.GetMetaData()
+	if len(e.MetaData) != len(otherMetaData) {
+		return false
+	}
+	for key, value := range e.MetaData {
+		otherValue, ok := otherMetaData[key]
+		if !ok || !reflect.DeepEqual(value, otherValue) {
+			return false
+		}
+	}
+	return true
+}
+
 func (e richError) ToString(format RichErrorOutputFormat) string {
 	switch format {
 	case CustomOutput:
-		return e.ToCustomString(customOutputFunction)
+		return e.ToCustomString(getCustomOutputFunction())
 	case DetailedOutput:
 		return e.detailedOutputString("\n", "\t")
 	case FullOutputFormatted:
 		return e.fullOutputString("\n", "\t")
 	case FullOutputInline:
 		return e.fullOutputString(" --- ", "")
+	case FullOutputRecursive:
+		return e.renderFullOutput("\n", "\t", true, newRenderState())
+	case ColorOutput:
+		return e.colorOutputString()
+	case LogfmtOutput:
+		return e.logfmtOutputString()
 	case ShortDetailedOutput:
 		return e.shortDetailedOutputString(" - ")
+	case JSONOutput:
+		return e.jsonOutputString(jsonIndent)
+	case JSONInlineOutput:
+		return e.jsonOutputString("")
+	case ShortNoTimeOutput:
+		return e.shortNoTimeOutputString(" - ")
 	default: // ShortOutput is default?
 		return e.shortOutputString(" - ")
 	}
 }
 
+// ToCustomString renders the error with the provided custom output function.
+// If the function is nil the error falls back to FullOutputFormatted rather than panicking, so Error() is always safe to call.
 func (e richError) ToCustomString(cof CustomOutputFunc) string {
 	if cof == nil {
-		panic("CustomOutput mode is selected and the provided CustomOutputFunction is nil")
+		return e.fullOutputString("\n", "\t")
 	}
 	return cof(e)
 }
 
 func (e richError) Error() string {
-	return e.ToString(errorOutputFormat)
+	return e.ToString(e.getErrorOutputFormat())
+}
+
+// GetEffectiveOutputFormat returns the output format Error() renders the error with, which is the format set with
+// SetOutputFormat or the global output format when none was set.
+func (e richError) GetEffectiveOutputFormat() RichErrorOutputFormat {
+	return e.getErrorOutputFormat()
+}
+
+// getErrorOutputFormat returns the output format of the error, or the global output format when none was set.
+func (e richError) getErrorOutputFormat() RichErrorOutputFormat {
+	if e.outputFormat != NotSpecified {
+		return e.outputFormat
+	}
+	outputSettingsMutex.RLock()
+	defer outputSettingsMutex.RUnlock()
+	return errorOutputFormat
+}
+
+// CanonicalString returns a deterministic representation of the error for snapshot tests.
+// It contains the code, message, sorted tags, sorted metadata and the top stack frame with its path shortened to the file name.
+// The occurred at time is omitted so errors that differ only in when they happened produce the same string.
+func (e richError) CanonicalString() string {
+	var messageBuffer bytes.Buffer
+	messageBuffer.WriteString(fmt.Sprintf("CODE: %s\nMESSAGE: %s", e.ErrCode, e.Message))
+	tags := make([]string, len(e.Tags))
+	copy(tags, e.Tags)
+	sort.Strings(tags)
+	messageBuffer.WriteString(fmt.Sprintf("\nTAGS: %s", strings.Join(tags, ", ")))
+	redactedMetaData := e.redactedMetaData()
+	metaData := make([]string, 0, len(redactedMetaData))
+	for _, key := range e.sortedMetaDataKeys() {
+		metaData = append(metaData, fmt.Sprintf("%s=%v", key, redactedMetaData[key]))
+	}
+	messageBuffer.WriteString(fmt.Sprintf("\nMETADATA: %s", strings.Join(metaData, ", ")))
+	if stack := e.stack(); len(stack) > 0 {
+		topFrame := stack[0]
+		messageBuffer.WriteString(fmt.Sprintf("\nTOP_FRAME: %s:%d %s", filepath.Base(topFrame.File), topFrame.Line, path.Base(topFrame.Function)))
+	}
+	return messageBuffer.String()
+}
+
+// Format implements fmt.Formatter. %+v prints the full formatted output including the stack,
+// %v and %s print the error code and message, e.g. "NotFound - user not found", and %q prints the quoted short output.
+func (e richError) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if f.Flag('+') {
+			io.WriteString(f, e.fullOutputString("\n", "\t"))
+			return
+		}
+		io.WriteString(f, e.shortNoTimeOutputString(" - "))
+	case 's':
+		io.WriteString(f, e.shortNoTimeOutputString(" - "))
+	case 'q':
+		io.WriteString(f, strconv.Quote(e.shortOutputString(" - ")))
+	default:
+		fmt.Fprintf(f, "%%!%c(richError=%s)", verb, e.shortOutputString(" - "))
+	}
+}
+
+// DebugString returns the full formatted output along with the raw payload if one was attached.
+// The raw payload may contain sensitive data so it is never included in the standard output formats.
+func (e richError) DebugString() string {
+	output := e.fullOutputString("\n", "\t")
+	if e.rawPayload == nil {
+		return output
+	}
+	return fmt.Sprintf("%s\nRAW_PAYLOAD (%d of %d bytes): %q", output, len(e.rawPayload), e.rawPayloadLength, e.rawPayload)
 }
 
 func (e richError) shortOutputString(seperator string) string {
-	return fmt.Sprintf("%s%s%s%s%s", e.OccurredAt.String(), seperator, e.ErrCode, seperator, e.Message)
+	return fmt.Sprintf("%s%s%s%s%s", e.formatTimestamp(e.OccurredAt), seperator, e.ErrCode, seperator, e.Message)
+}
+
+func (e richError) shortNoTimeOutputString(seperator string) string {
+	return fmt.Sprintf("%s%s%s", e.ErrCode, seperator, e.Message)
 }
 
 func (e richError) shortDetailedOutputString(seperator string) string {
-	return fmt.Sprintf("%s%s%s%s%s%s%s:%s", e.OccurredAt.String(), seperator, e.ErrCode, seperator, e.Message, seperator, e.Source, e.Line)
+	return fmt.Sprintf("%s%s%s%s%s%s%s:%s", e.formatTimestamp(e.OccurredAt), seperator, e.ErrCode, seperator, e.Message, seperator, e.displayPath(e.Source), e.Line)
 }
 
 func (e richError) detailedOutputString(partSeperator, indentString string) string {
-	var messageBuffer bytes.Buffer
-	timeStampMsg := fmt.Sprintf("ERROR - %s", e.OccurredAt.String())
+	messageBuffer := getBuffer()
+	defer putBuffer(messageBuffer)
+	timeStampMsg := fmt.Sprintf("ERROR - %s", e.formatTimestamp(e.OccurredAt))
 	messageBuffer.WriteString(timeStampMsg)
 	if e.Source != "" {
-		sourceSection := fmt.Sprintf("%sSOURCE: %s:%s", partSeperator, e.Source, e.Line)
+		sourceSection := fmt.Sprintf("%sSOURCE: %s:%s", partSeperator, e.displayPath(e.Source), e.Line)
 		messageBuffer.WriteString(sourceSection)
 	}
 	if e.ErrCode != "" {
 		errCodeSection := fmt.Sprintf("%sERRCODE: %s", partSeperator, e.ErrCode)
 		messageBuffer.WriteString(errCodeSection)
 	}
+	if e.Category != "" {
+		categorySection := fmt.Sprintf("%sCATEGORY: %s", partSeperator, e.Category)
+		messageBuffer.WriteString(categorySection)
+	}
+	severitySection := fmt.Sprintf("%sSEVERITY: %s", partSeperator, e.GetSeverity().String())
+	messageBuffer.WriteString(severitySection)
 	if e.Message != "" {
 		messageSection := fmt.Sprintf("%sMESSAGE: %s", partSeperator, e.Message)
 		messageBuffer.WriteString(messageSection)
 	}
+	if e.RetryAfter != nil {
+		retryAfterSection := fmt.Sprintf("%sRETRY_AFTER: %s", partSeperator, e.RetryAfter.String())
+		messageBuffer.WriteString(retryAfterSection)
+	}
 	if len(e.MetaData) > 0 {
-		messageBuffer.WriteString("METADATA:")
-		for key, value := range e.MetaData {
-			metaDataMsg := fmt.Sprintf("%s%s%s: %v", partSeperator, indentString, key, value)
+		metaData := e.redactedMetaData()
+		writeSectionHeader(messageBuffer, partSeperator, "METADATA:")
+		for _, key := range e.sortedMetaDataKeys() {
+			metaDataMsg := fmt.Sprintf("%s%s%s: %v", partSeperator, indentString, key, metaData[key])
 			messageBuffer.WriteString(metaDataMsg)
 		}
 	}
 	return messageBuffer.String()
 }
 
+func copyMetaData(metaData map[string]interface{}) map[string]interface{} {
+	if metaData == nil {
+		return nil
+	}
+	metaDataCopy := make(map[string]interface{}, len(metaData))
+	for key, value := range metaData {
+		metaDataCopy[key] = value
+	}
+	return metaDataCopy
+}
+
+// sortedMetaDataKeys returns the metadata keys in lexicographic order so output is stable between calls.
+func (e richError) sortedMetaDataKeys() []string {
+	keys := make([]string, 0, len(e.MetaData))
+	for key := range e.MetaData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (e richError) HasStack() bool {
+	if e.lazyStack != nil {
+		return len(e.lazyStack.pcs) > 0
+	}
 	return len(e.Stack) > 0
 }
 
+// HasValidPCs reports whether every stack entry has a program counter that resolves to a function in the current process.
+// When an entry already has a function name it must match the resolved function, which catches stale program counters
+// from errors that were captured in another process and reconstructed from JSON.
+func (e richError) HasValidPCs() bool {
+	stack := e.stack()
+	if len(stack) == 0 {
+		return false
+	}
+	for _, entry := range stack {
+		if entry.isOmittedFrames() {
+			continue
+		}
+		if entry.PC == 0 {
+			return false
+		}
+		fn := runtime.FuncForPC(entry.PC)
+		if fn == nil {
+			return false
+		}
+		if entry.Function != "" && entry.Function != fn.Name() {
+			return false
+		}
+	}
+	return true
+}
+
 func (e richError) fullOutputString(partSeperator, indentString string) string {
-	var messageBuffer bytes.Buffer
-	timeStampMsg := fmt.Sprintf("TIMESTAMP: %s", e.OccurredAt.String())
+	return e.renderFullOutput(partSeperator, indentString, false, newRenderState())
+}
+
+// fullOutputStringWithState renders the full output. When recursive is true inner rich errors are rendered in full
+// rather than with their Error method.
+func (e richError) fullOutputStringWithState(partSeperator, indentString string, recursive bool, state *renderState) string {
+	messageBuffer := getBuffer()
+	defer putBuffer(messageBuffer)
+	timeStampMsg := fmt.Sprintf("TIMESTAMP: %s", e.formatTimestamp(e.OccurredAt))
 	messageBuffer.WriteString(timeStampMsg)
 	if e.Source != "" {
-		sourceSection := fmt.Sprintf("%sSOURCE: %s", partSeperator, e.Source)
+		sourceSection := fmt.Sprintf("%sSOURCE: %s", partSeperator, e.displayPath(e.Source))
 		messageBuffer.WriteString(sourceSection)
 	}
 	if e.Function != "" {
@@ -322,36 +1256,83 @@ func (e richError) fullOutputString(partSeperator, indentString string) string {
 		LineNumberSection := fmt.Sprintf("%sLINE_NUM: %s", partSeperator, e.Line)
 		messageBuffer.WriteString(LineNumberSection)
 	}
+	if e.GoroutineID != 0 {
+		goroutineSection := fmt.Sprintf("%sGOROUTINE: %d", partSeperator, e.GoroutineID)
+		messageBuffer.WriteString(goroutineSection)
+	}
 	if e.ErrCode != "" {
 		errCodeSection := fmt.Sprintf("%sERRCODE: %s", partSeperator, e.ErrCode)
 		messageBuffer.WriteString(errCodeSection)
 	}
+	if e.Category != "" {
+		categorySection := fmt.Sprintf("%sCATEGORY: %s", partSeperator, e.Category)
+		messageBuffer.WriteString(categorySection)
+	}
+	severitySection := fmt.Sprintf("%sSEVERITY: %s", partSeperator, e.GetSeverity().String())
+	messageBuffer.WriteString(severitySection)
 	if e.Message != "" {
 		messageSection := fmt.Sprintf("%sMESSAGE: %s", partSeperator, e.Message)
 		messageBuffer.WriteString(messageSection)
 	}
-	if len(e.Stack) > 0 {
-		stackBuffer := bytes.Buffer{}
+	if e.RetryAfter != nil {
+		retryAfterSection := fmt.Sprintf("%sRETRY_AFTER: %s", partSeperator, e.RetryAfter.String())
+		messageBuffer.WriteString(retryAfterSection)
+	}
+	if stack := e.stack(); len(stack) > 0 {
 		firstLine := fmt.Sprintf("%sSTACK: ", partSeperator)
-		stackBuffer.WriteString(firstLine)
-		for _, frame := range e.Stack {
-			stackFrame := fmt.Sprintf("%s%s%s", strings.Repeat(indentString, frame.Depth), frame.String(), partSeperator)
-			stackBuffer.WriteString(stackFrame)
+		messageBuffer.WriteString(firstLine)
+		for _, frame := range stack {
+			frame.File = e.displayPath(frame.File)
+			stackFrame := fmt.Sprintf("%s%s%s", strings.Repeat(indentString, frame.Depth), formatStackFrame(frame), partSeperator)
+			messageBuffer.WriteString(stackFrame)
 		}
-		messageBuffer.WriteString(stackBuffer.String())
+	} else if getAlwaysEmitStackSection() {
+		emptyStackSection := fmt.Sprintf("%sSTACK: (none captured)%s", partSeperator, partSeperator)
+		messageBuffer.WriteString(emptyStackSection)
+	}
+	if len(e.Breadcrumbs) > 0 {
+		writeSectionHeader(messageBuffer, partSeperator, "BREADCRUMBS:")
+		for i, breadcrumb := range e.Breadcrumbs {
+			breadcrumbMessage := fmt.Sprintf("%s%s#%d: %s", partSeperator, indentString, i+1, breadcrumb.format(e.formatTimestamp(breadcrumb.Timestamp)))
+			messageBuffer.WriteString(breadcrumbMessage)
+		}
+		messageBuffer.WriteString(partSeperator)
+	}
+	if e.Cause != nil {
+		writeSectionHeader(messageBuffer, partSeperator, "CAUSE: ")
+		causeMessage := fmt.Sprintf("%s%s", errorWithState(e.inheritRedactedKeys(e.Cause), state), partSeperator)
+		messageBuffer.WriteString(causeMessage)
 	}
 	if len(e.InnerErrors) > 0 {
-		messageBuffer.WriteString("INNER ERRORS:")
+		writeSectionHeader(messageBuffer, partSeperator, "INNER ERRORS:")
 		for i, err := range e.InnerErrors {
-			innerErrMessage := fmt.Sprintf("%s%sERROR #%d: %s", partSeperator, strings.Repeat(indentString, i+1), i+1, err.Error())
+			err = e.inheritRedactedKeys(err)
+			var innerErrString string
+			if innerErr, ok := err.(richError); ok && recursive {
+				innerErrString = innerErr.recursiveOutputString(partSeperator, indentString, state)
+			} else {
+				innerErrString = errorWithState(err, state)
+			}
+			// sibling errors share one level of indentation, the output of nested
+			// errors is indented further by indentNestedOutput.
+			innerErrMessage := fmt.Sprintf("%s%sERROR #%d: %s", partSeperator, indentString, i+1, innerErrString)
 			messageBuffer.WriteString(innerErrMessage)
 		}
 		messageBuffer.WriteString(partSeperator)
 	}
+	if len(e.RelatedErrors) > 0 {
+		writeSectionHeader(messageBuffer, partSeperator, "RELATED ERRORS:")
+		for i, relatedErr := range e.RelatedErrors {
+			relatedErrMessage := fmt.Sprintf("%s%sERROR #%d (%s): %s", partSeperator, indentString, i+1, relatedErr.Relation, errorWithState(e.inheritRedactedKeys(relatedErr.Err), state))
+			messageBuffer.WriteString(relatedErrMessage)
+		}
+		messageBuffer.WriteString(partSeperator)
+	}
 	if len(e.MetaData) > 0 {
-		messageBuffer.WriteString("METADATA:")
-		for key, value := range e.MetaData {
-			metaDataMsg := fmt.Sprintf("%s%s%s: %v", partSeperator, indentString, key, value)
+		metaData := e.redactedMetaData()
+		writeSectionHeader(messageBuffer, partSeperator, "METADATA:")
+		for _, key := range e.sortedMetaDataKeys() {
+			metaDataMsg := fmt.Sprintf("%s%s%s: %v", partSeperator, indentString, key, metaData[key])
 			messageBuffer.WriteString(metaDataMsg)
 		}
 	}
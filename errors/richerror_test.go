@@ -0,0 +1,2440 @@
+package errors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithRawPayload(t *testing.T) {
+	type rawPayloadTestCase struct {
+		name            string
+		payload         []byte
+		maxBytes        int
+		expectedPayload string
+	}
+	testCases := []rawPayloadTestCase{
+		{
+			name:            "payload under limit",
+			payload:         []byte("abc"),
+			maxBytes:        10,
+			expectedPayload: "abc",
+		},
+		{
+			name:            "payload over limit is truncated",
+			payload:         []byte("abcdefghij"),
+			maxBytes:        4,
+			expectedPayload: "abcd",
+		},
+		{
+			name:            "no limit",
+			payload:         []byte("abcdefghij"),
+			maxBytes:        0,
+			expectedPayload: "abcdefghij",
+		},
+	}
+	for _, test := range testCases {
+		err := NewRichError("TestCode", "test message").WithRawPayload(test.payload, test.maxBytes)
+		payload, ok := err.GetRawPayload()
+		if !ok {
+			t.Errorf("%s test failed: expected raw payload to be present", test.name)
+		}
+		if string(payload) != test.expectedPayload {
+			t.Errorf("%s test failed: payload not expected: (expected: %s) (actual: %s)", test.name, test.expectedPayload, payload)
+		}
+	}
+}
+
+func TestWithRawPayloadCopiesInput(t *testing.T) {
+	input := []byte("abc")
+	err := NewRichError("TestCode", "test message").WithRawPayload(input, 10)
+	input[0] = 'x'
+	payload, _ := err.GetRawPayload()
+	if string(payload) != "abc" {
+		t.Errorf("raw payload was modified through the input slice: %s", payload)
+	}
+}
+
+func TestRawPayloadExcludedFromOutput(t *testing.T) {
+	secret := "super-secret-payload"
+	err := NewRichError("TestCode", "test message").WithRawPayload([]byte(secret), 0)
+	formats := []RichErrorOutputFormat{DetailedOutput, FullOutputFormatted, FullOutputInline, ShortDetailedOutput, ShortOutput}
+	for _, format := range formats {
+		if strings.Contains(err.ToString(format), secret) {
+			t.Errorf("raw payload found in output format %d", format)
+		}
+	}
+	jsonData, _ := json.Marshal(err)
+	if strings.Contains(string(jsonData), secret) {
+		t.Errorf("raw payload found in json output: %s", jsonData)
+	}
+	if !strings.Contains(err.DebugString(), secret) {
+		t.Errorf("raw payload not found in debug output: %s", err.DebugString())
+	}
+}
+
+func TestGetRawPayloadNotSet(t *testing.T) {
+	err := NewRichError("TestCode", "test message")
+	if _, ok := err.GetRawPayload(); ok {
+		t.Error("expected no raw payload to be present")
+	}
+}
+
+func recursiveStackError(depth int) RichError {
+	if depth == 0 {
+		return NewRichError("TestCode", "test message").WithStack(0)
+	}
+	return recursiveStackError(depth - 1)
+}
+
+func TestWithStackCapturesDeepStacks(t *testing.T) {
+	depth := 50
+	err := recursiveStackError(depth)
+	recursiveFrames := 0
+	for _, frame := range err.GetStack() {
+		if strings.HasSuffix(frame.Function, ".recursiveStackError") {
+			recursiveFrames++
+		}
+	}
+	if recursiveFrames != depth+1 {
+		t.Errorf("stack not fully captured: (expected: %d recursive frames) (actual: %d)", depth+1, recursiveFrames)
+	}
+	for i, frame := range err.GetStack() {
+		if frame.Depth != i {
+			t.Errorf("stack frame depth not expected: (expected: %d) (actual: %d)", i, frame.Depth)
+		}
+	}
+	lastFrame := err.GetStack()[len(err.GetStack())-1]
+	if lastFrame.Function != "runtime.goexit" {
+		t.Errorf("last stack frame not expected: (expected: runtime.goexit) (actual: %s)", lastFrame.Function)
+	}
+}
+
+func TestSanitizeForClientRichError(t *testing.T) {
+	err := NewRichError("NotFound", "item not found").
+		WithStack(0).
+		AddTag("database").
+		AddError(NewRichError("QueryFailed", "select failed")).
+		AddMetaData("itemId", 42).
+		AddMetaData("query", "select * from items")
+	wrappedErr := fmt.Errorf("handler failed: %w", err)
+	sanitizedErr := SanitizeForClient(wrappedErr, []string{"itemId", "missing"})
+	if sanitizedErr.GetErrorCode() != "NotFound" {
+		t.Errorf("sanitized error code not expected: (expected: NotFound) (actual: %s)", sanitizedErr.GetErrorCode())
+	}
+	if sanitizedErr.GetErrorMessage() != "item not found" {
+		t.Errorf("sanitized error message not expected: (expected: item not found) (actual: %s)", sanitizedErr.GetErrorMessage())
+	}
+	if sanitizedErr.HasStack() || sanitizedErr.GetSource() != "" || sanitizedErr.GetLineNumber() != "" {
+		t.Error("sanitized error should not have a stack or source location")
+	}
+	if len(sanitizedErr.GetTags()) != 0 || len(sanitizedErr.GetErrors()) != 0 {
+		t.Error("sanitized error should not have tags or inner errors")
+	}
+	if len(sanitizedErr.GetMetaData()) != 1 {
+		t.Errorf("sanitized error metadata not expected: %v", sanitizedErr.GetMetaData())
+	}
+	if value, _ := sanitizedErr.GetMetaDataItem("itemId"); value != 42 {
+		t.Errorf("sanitized error metadata item not expected: (expected: 42) (actual: %v)", value)
+	}
+}
+
+func TestSanitizeForClientPlainError(t *testing.T) {
+	sanitizedErr := SanitizeForClient(goerrors.New("connection refused to 10.0.0.1"), nil)
+	if sanitizedErr.GetErrorCode() != ErrCodeSanitized {
+		t.Errorf("sanitized error code not expected: (expected: %s) (actual: %s)", ErrCodeSanitized, sanitizedErr.GetErrorCode())
+	}
+	if strings.Contains(sanitizedErr.Error(), "10.0.0.1") {
+		t.Errorf("sanitized error leaked the original message: %s", sanitizedErr.Error())
+	}
+	if SanitizeForClient(nil, nil) != nil {
+		t.Error("sanitizing a nil error should return nil")
+	}
+}
+
+func TestMetaDataOutputOrder(t *testing.T) {
+	err := NewRichError("TestCode", "test message").
+		AddMetaData("zeta", 1).
+		AddMetaData("alpha", 2).
+		AddMetaData("mu", 3).
+		AddMetaData("beta", 4)
+	expectedOrder := []string{"alpha", "beta", "mu", "zeta"}
+	for _, format := range []RichErrorOutputFormat{DetailedOutput, FullOutputFormatted, FullOutputInline} {
+		output := err.ToString(format)
+		lastIndex := -1
+		for _, key := range expectedOrder {
+			index := strings.Index(output, key+":")
+			if index <= lastIndex {
+				t.Errorf("metadata key %s out of order in output format %d: %s", key, format, output)
+			}
+			lastIndex = index
+		}
+	}
+}
+
+func TestWithRetryAfter(t *testing.T) {
+	err := NewRichError("RateLimited", "too many requests")
+	if _, ok := err.GetRetryAfter(); ok {
+		t.Error("expected no retry after to be present")
+	}
+	err = err.WithRetryAfter(30 * time.Second)
+	retryAfter, ok := err.GetRetryAfter()
+	if !ok || retryAfter != 30*time.Second {
+		t.Errorf("retry after not expected: (expected: %s) (actual: %s)", 30*time.Second, retryAfter)
+	}
+	if !strings.Contains(err.ToString(FullOutputFormatted), "RETRY_AFTER: 30s") {
+		t.Errorf("retry after not found in full output: %s", err.ToString(FullOutputFormatted))
+	}
+	jsonData, _ := json.Marshal(err)
+	if !strings.Contains(string(jsonData), `"retryAfter":30000000000`) {
+		t.Errorf("retry after not found in json output: %s", jsonData)
+	}
+}
+
+func TestMetaDataIsNotShared(t *testing.T) {
+	sharedMetaData := map[string]interface{}{"shared": true}
+	base := NewRichError("TestCode", "test message").WithMetaData(sharedMetaData)
+	first := base.AddMetaData("first", 1)
+	second := base.AddMetaData("second", 2)
+	if _, ok := first.GetMetaDataItem("second"); ok {
+		t.Error("metadata added to the second error leaked into the first error")
+	}
+	if _, ok := second.GetMetaDataItem("first"); ok {
+		t.Error("metadata added to the first error leaked into the second error")
+	}
+	if _, ok := base.GetMetaDataItem("first"); ok {
+		t.Error("metadata added to a derived error leaked into the base error")
+	}
+	if len(sharedMetaData) != 1 {
+		t.Errorf("metadata map passed to WithMetaData was modified: %v", sharedMetaData)
+	}
+	sharedMetaData["late"] = true
+	if _, ok := base.GetMetaDataItem("late"); ok {
+		t.Error("changes to the map passed to WithMetaData leaked into the error")
+	}
+}
+
+func TestReSymbolizeValidPCs(t *testing.T) {
+	err := NewRichError("TestCode", "test message").WithStack(0)
+	if !err.HasValidPCs() {
+		t.Fatal("expected freshly captured stack to have valid program counters")
+	}
+	pcOnlyErr := richError{ErrCode: "TestCode"}
+	for _, entry := range err.GetStack() {
+		pcOnlyErr.Stack = append(pcOnlyErr.Stack, callStackEntry{Depth: entry.Depth, PC: entry.PC})
+	}
+	if !pcOnlyErr.HasValidPCs() {
+		t.Fatal("expected stack with only program counters to have valid program counters")
+	}
+	reSymbolizedErr := pcOnlyErr.ReSymbolize()
+	expectedFrame := err.GetStack()[0]
+	actualFrame := reSymbolizedErr.GetStack()[0]
+	if actualFrame.File != expectedFrame.File || actualFrame.Line != expectedFrame.Line || actualFrame.Function != expectedFrame.Function {
+		t.Errorf("re-symbolized frame not expected: (expected: %s) (actual: %s)", expectedFrame.String(), actualFrame.String())
+	}
+	if reSymbolizedErr.GetSource() != err.GetSource() || reSymbolizedErr.GetLineNumber() != err.GetLineNumber() || reSymbolizedErr.GetFunction() != err.GetFunction() {
+		t.Errorf("re-symbolized location not expected: (expected: %s:%s) (actual: %s:%s)", err.GetSource(), err.GetLineNumber(), reSymbolizedErr.GetSource(), reSymbolizedErr.GetLineNumber())
+	}
+}
+
+func TestReSymbolizeStalePCs(t *testing.T) {
+	type stalePCTestCase struct {
+		name  string
+		stack []callStackEntry
+	}
+	validPC := NewRichError("TestCode", "test message").WithStack(0).GetStack()[0].PC
+	testCases := []stalePCTestCase{
+		{
+			name:  "no stack",
+			stack: nil,
+		},
+		{
+			name:  "zero program counter",
+			stack: []callStackEntry{{File: "/app/main.go", Function: "main.main", Line: 10}},
+		},
+		{
+			name:  "program counter from another process",
+			stack: []callStackEntry{{File: "/app/main.go", Function: "main.somethingElse", Line: 10, PC: validPC}},
+		},
+	}
+	for _, test := range testCases {
+		err := richError{ErrCode: "TestCode", Source: "/app/main.go", Line: "10", Stack: test.stack}
+		if err.HasValidPCs() {
+			t.Errorf("%s test failed: expected program counters to be invalid", test.name)
+		}
+		reSymbolizedErr := err.ReSymbolize()
+		if reSymbolizedErr.GetSource() != "/app/main.go" || reSymbolizedErr.GetLineNumber() != "10" {
+			t.Errorf("%s test failed: error should not change when program counters are invalid", test.name)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	err := NewRichError("TestCode", "test message").WithStack(0)
+	plusOutput := fmt.Sprintf("%+v", err)
+	if !strings.Contains(plusOutput, "STACK:") {
+		t.Errorf("%%+v output should include the stack: %s", plusOutput)
+	}
+	for _, verb := range []string{"%v", "%s"} {
+		expected := "TestCode - test message"
+		if output := fmt.Sprintf(verb, err); output != expected {
+			t.Errorf("%s output not expected: (expected: %s) (actual: %s)", verb, expected, output)
+		}
+	}
+	quotedOutput := fmt.Sprintf("%q", err)
+	if quotedOutput != strconv.Quote(err.ToString(ShortOutput)) {
+		t.Errorf("%%q output not expected: (expected: %s) (actual: %s)", strconv.Quote(err.ToString(ShortOutput)), quotedOutput)
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	innerRichErr := NewRichError("InnerCode", "inner message").AddTag("inner")
+	err := NewRichError("OuterCode", "outer message").
+		WithStack(0).
+		AddTag("outer").
+		AddMetaData("key", "value").
+		AddError(innerRichErr).
+		AddError(goerrors.New("plain error"))
+	var output map[string]interface{}
+	if jsonErr := json.Unmarshal([]byte(err.ToString(JSONOutput)), &output); jsonErr != nil {
+		t.Fatalf("json output could not be parsed: %s", jsonErr.Error())
+	}
+	if output["code"] != "OuterCode" || output["message"] != "outer message" {
+		t.Errorf("json output code and message not expected: %v", output)
+	}
+	if stack, ok := output["stack"].([]interface{}); !ok || len(stack) == 0 {
+		t.Errorf("json output stack not expected: %v", output["stack"])
+	}
+	innerErrors, ok := output["innerErrors"].([]interface{})
+	if !ok || len(innerErrors) != 2 {
+		t.Fatalf("json output inner errors not expected: %v", output["innerErrors"])
+	}
+	nestedErr, ok := innerErrors[0].(map[string]interface{})
+	if !ok || nestedErr["code"] != "InnerCode" {
+		t.Errorf("json output inner rich error should be nested: %v", innerErrors[0])
+	}
+	plainErr, ok := innerErrors[1].(map[string]interface{})
+	if !ok || plainErr["message"] != "plain error" {
+		t.Errorf("json output plain inner error not expected: %v", innerErrors[1])
+	}
+}
+
+func TestJSONOutputUnsupportedMetaData(t *testing.T) {
+	err := NewRichError("TestCode", "test message").AddMetaData("channel", make(chan int))
+	var output map[string]interface{}
+	if jsonErr := json.Unmarshal([]byte(err.ToString(JSONOutput)), &output); jsonErr != nil {
+		t.Fatalf("json output could not be parsed: %s", jsonErr.Error())
+	}
+	if output["code"] != "TestCode" || output["jsonError"] == nil {
+		t.Errorf("json fallback output not expected: %v", output)
+	}
+}
+
+func TestPromoteInnerTags(t *testing.T) {
+	firstInnerErr := NewRichError("FirstInner", "first inner").WithTags([]string{"retryable", "database"})
+	secondInnerErr := NewRichError("SecondInner", "second inner").WithTags([]string{"retryable"})
+	err := NewRichError("Outer", "outer").
+		AddTag("outer").
+		AddError(firstInnerErr).
+		AddError(goerrors.New("plain error")).
+		AddError(secondInnerErr).
+		PromoteInnerTags("inner:")
+	expectedTags := []string{"outer", "inner:retryable", "inner:database"}
+	if strings.Join(err.GetTags(), ",") != strings.Join(expectedTags, ",") {
+		t.Errorf("promoted tags not expected: (expected: %v) (actual: %v)", expectedTags, err.GetTags())
+	}
+	innerErr := err.GetErrors()[0].(ReadOnlyRichError)
+	if strings.Join(innerErr.GetTags(), ",") != "retryable,database" {
+		t.Errorf("inner error tags were modified: %v", innerErr.GetTags())
+	}
+}
+
+type testErrorCode string
+
+const testErrorCodeNotFound testErrorCode = "NotFound"
+
+func TestTypedConstructors(t *testing.T) {
+	err := New(testErrorCodeNotFound, "not found")
+	if err.GetErrorCode() != "NotFound" {
+		t.Errorf("error code not expected: (expected: NotFound) (actual: %s)", err.GetErrorCode())
+	}
+	typedErr := NewRichErrorTyped(ErrorCode("Conflict"), "conflict")
+	if typedErr.GetErrorCode() != "Conflict" {
+		t.Errorf("error code not expected: (expected: Conflict) (actual: %s)", typedErr.GetErrorCode())
+	}
+	if typedErr.GetErrorMessage() != "conflict" {
+		t.Errorf("error message not expected: (expected: conflict) (actual: %s)", typedErr.GetErrorMessage())
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	err := NewRichError("OuterCode", "outer message").
+		AddError(NewRichError("InnerCode", "inner message").AddError(io.EOF)).
+		AddError(fmt.Errorf("wrapped: %w", io.ErrUnexpectedEOF))
+	jsonData, marshalErr := json.Marshal(err)
+	if marshalErr != nil {
+		t.Fatalf("failed to marshal error: %s", marshalErr.Error())
+	}
+	var output struct {
+		Code         string `json:"code"`
+		OutputFormat string `json:"outputFormat"`
+		InnerErrors  []struct {
+			Code        string `json:"code"`
+			Message     string `json:"message"`
+			InnerErrors []struct {
+				Message string `json:"message"`
+			} `json:"innerErrors"`
+		} `json:"innerErrors"`
+	}
+	if unmarshalErr := json.Unmarshal(jsonData, &output); unmarshalErr != nil {
+		t.Fatalf("failed to unmarshal error json: %s", unmarshalErr.Error())
+	}
+	if output.Code != "OuterCode" || output.OutputFormat != "FullOutputFormatted" {
+		t.Errorf("json code or output format not expected: %s", jsonData)
+	}
+	if len(output.InnerErrors) != 2 {
+		t.Fatalf("json inner errors not expected: %s", jsonData)
+	}
+	if output.InnerErrors[0].Code != "InnerCode" || len(output.InnerErrors[0].InnerErrors) != 1 || output.InnerErrors[0].InnerErrors[0].Message != "EOF" {
+		t.Errorf("json nested rich inner error not expected: %s", jsonData)
+	}
+	if output.InnerErrors[1].Message != "wrapped: unexpected EOF" {
+		t.Errorf("json plain inner error not expected: %s", jsonData)
+	}
+}
+
+func TestUnmarshalRichError(t *testing.T) {
+	originalErr := NewRichError("OuterCode", "outer message").
+		WithStack(0).
+		WithTags([]string{"a", "b"}).
+		AddMetaData("key", "value").
+		AddError(NewRichError("InnerCode", "inner message").AddError(io.EOF)).
+		AddError(goerrors.New("plain error"))
+	jsonData, err := json.Marshal(originalErr)
+	if err != nil {
+		t.Fatalf("failed to marshal error: %s", err.Error())
+	}
+	reloadedErr, err := UnmarshalRichError(jsonData)
+	if err != nil {
+		t.Fatalf("failed to unmarshal error: %s", err.Error())
+	}
+	if reloadedErr.GetErrorCode() != originalErr.GetErrorCode() || reloadedErr.GetErrorMessage() != originalErr.GetErrorMessage() {
+		t.Errorf("reloaded code or message not expected: (expected: %s %s) (actual: %s %s)", originalErr.GetErrorCode(), originalErr.GetErrorMessage(), reloadedErr.GetErrorCode(), reloadedErr.GetErrorMessage())
+	}
+	if reloadedErr.GetSource() != originalErr.GetSource() || reloadedErr.GetFunction() != originalErr.GetFunction() || reloadedErr.GetLineNumber() != originalErr.GetLineNumber() {
+		t.Errorf("reloaded location not expected: (expected: %s) (actual: %s)", originalErr.GetSource(), reloadedErr.GetSource())
+	}
+	if !reloadedErr.GetOccurredAt().Equal(originalErr.GetOccurredAt()) {
+		t.Errorf("reloaded occurred at not expected: (expected: %s) (actual: %s)", originalErr.GetOccurredAt(), reloadedErr.GetOccurredAt())
+	}
+	if strings.Join(reloadedErr.GetTags(), ",") != "a,b" {
+		t.Errorf("reloaded tags not expected: %v", reloadedErr.GetTags())
+	}
+	if value, _ := reloadedErr.GetMetaDataItem("key"); value != "value" {
+		t.Errorf("reloaded metadata not expected: %v", reloadedErr.GetMetaData())
+	}
+	// program counters are not serialized by default, so only the symbolized fields are reloaded.
+	expectedFrame := originalErr.GetStack()[0]
+	expectedFrame.Entry, expectedFrame.PC = 0, 0
+	if len(reloadedErr.GetStack()) != len(originalErr.GetStack()) || reloadedErr.GetStack()[0] != expectedFrame {
+		t.Errorf("reloaded stack not expected")
+	}
+	innerErrors := reloadedErr.GetErrors()
+	if len(innerErrors) != 2 {
+		t.Fatalf("reloaded inner errors not expected: %v", innerErrors)
+	}
+	innerRichErr, ok := innerErrors[0].(ReadOnlyRichError)
+	if !ok || innerRichErr.GetErrorCode() != "InnerCode" {
+		t.Errorf("reloaded inner rich error not expected: %v", innerErrors[0])
+	} else if len(innerRichErr.GetErrors()) != 1 || innerRichErr.GetErrors()[0].Error() != "EOF" {
+		t.Errorf("reloaded nested inner error not expected: %v", innerRichErr.GetErrors())
+	}
+	if _, ok := innerErrors[1].(ReadOnlyRichError); ok || innerErrors[1].Error() != "plain error" {
+		t.Errorf("reloaded plain inner error not expected: %v", innerErrors[1])
+	}
+}
+
+func TestUnmarshalRichErrorNullInnerError(t *testing.T) {
+	originalErr := NewRichError("OuterCode", "outer message").
+		AddError(goerrors.New("placeholder error")).
+		AddError(io.EOF)
+	originalErr.GetErrors()[0] = nil
+	jsonData, err := json.Marshal(originalErr)
+	if err != nil {
+		t.Fatalf("failed to marshal error: %s", err.Error())
+	}
+	if !strings.Contains(string(jsonData), `"innerErrors":[null,`) {
+		t.Fatalf("null inner error test failed: nil inner error not marshaled as null: %s", jsonData)
+	}
+	reloadedErr, err := UnmarshalRichError(jsonData)
+	if err != nil {
+		t.Fatalf("failed to unmarshal error: %s", err.Error())
+	}
+	if innerErrors := reloadedErr.GetErrors(); len(innerErrors) != 1 || innerErrors[0] == nil || innerErrors[0].Error() != "EOF" {
+		t.Errorf("null inner error test failed: reloaded inner errors not expected (expected: [EOF]) (actual: %v)", innerErrors)
+	}
+	reloadedErr, err = UnmarshalRichError([]byte(`{"code":"OuterCode","message":"outer message","cause":null,"relatedErrors":[{"relation":"compensation","error":null}]}`))
+	if err != nil {
+		t.Fatalf("failed to unmarshal error: %s", err.Error())
+	}
+	if reloadedErr.GetCause() != nil || len(reloadedErr.GetRelatedErrors()) != 0 {
+		t.Errorf("null inner error test failed: null cause and related errors should be skipped (actual: %v %v)", reloadedErr.GetCause(), reloadedErr.GetRelatedErrors())
+	}
+}
+
+func TestWithAction(t *testing.T) {
+	err := NewRichError("TestCode", "test message")
+	if _, ok := err.GetAction(); ok {
+		t.Error("expected no action to be present")
+	}
+	err = err.WithAction("checkout")
+	if action, ok := err.GetAction(); !ok || action != "checkout" {
+		t.Errorf("action not expected: (expected: checkout) (actual: %s)", action)
+	}
+	if !strings.Contains(err.ToString(JSONInlineOutput), `"action":"checkout"`) {
+		t.Errorf("action not found in json output: %s", err.ToString(JSONInlineOutput))
+	}
+}
+
+func TestAlwaysEmitStackSection(t *testing.T) {
+	err := NewRichError("TestCode", "test message")
+	if strings.Contains(err.ToString(FullOutputFormatted), "STACK:") {
+		t.Errorf("stack section should not be emitted by default when no stack was captured: %s", err.ToString(FullOutputFormatted))
+	}
+	SetGlobalAlwaysEmitStackSection(true)
+	defer SetGlobalAlwaysEmitStackSection(false)
+	if !strings.Contains(err.ToString(FullOutputFormatted), "\nSTACK: (none captured)\n") {
+		t.Errorf("empty stack section not found in output: %s", err.ToString(FullOutputFormatted))
+	}
+	stackErr := err.WithStack(0)
+	if strings.Contains(stackErr.ToString(FullOutputFormatted), "(none captured)") {
+		t.Errorf("empty stack section should not be emitted when a stack was captured: %s", stackErr.ToString(FullOutputFormatted))
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	err := NewRichError("TestCode", "test message")
+	if err.GetSeverity() != SeverityError {
+		t.Errorf("default severity not expected: (expected: %s) (actual: %s)", SeverityError, err.GetSeverity())
+	}
+	if !strings.Contains(err.ToString(JSONInlineOutput), `"severity":"error"`) {
+		t.Errorf("default severity not found in json output: %s", err.ToString(JSONInlineOutput))
+	}
+	err = err.WithSeverity(SeverityWarn)
+	if err.GetSeverity() != SeverityWarn {
+		t.Errorf("severity not expected: (expected: %s) (actual: %s)", SeverityWarn, err.GetSeverity())
+	}
+	for _, format := range []RichErrorOutputFormat{DetailedOutput, FullOutputFormatted} {
+		if !strings.Contains(err.ToString(format), "SEVERITY: warn") {
+			t.Errorf("severity not found in output format %d: %s", format, err.ToString(format))
+		}
+	}
+	reloadedErr, unmarshalErr := UnmarshalRichError([]byte(err.ToString(JSONOutput)))
+	if unmarshalErr != nil {
+		t.Fatalf("failed to unmarshal error: %s", unmarshalErr.Error())
+	}
+	if reloadedErr.GetSeverity() != SeverityWarn {
+		t.Errorf("reloaded severity not expected: (expected: %s) (actual: %s)", SeverityWarn, reloadedErr.GetSeverity())
+	}
+}
+
+func TestMoreSevereThan(t *testing.T) {
+	base := NewRichError("TestCode", "test message")
+	SetCategoryPrecedence("auth", "io")
+	defer SetCategoryPrecedence()
+	testCases := []struct {
+		name     string
+		err      RichError
+		other    ReadOnlyRichError
+		expected bool
+	}{
+		{name: "fatal more severe than error", err: base.WithSeverity(SeverityFatal), other: base.WithSeverity(SeverityError), expected: true},
+		{name: "warn not more severe than error", err: base.WithSeverity(SeverityWarn), other: base.WithSeverity(SeverityError), expected: false},
+		{name: "debug not more severe than info", err: base.WithSeverity(SeverityDebug), other: base.WithSeverity(SeverityInfo), expected: false},
+		{name: "equal errors", err: base, other: base, expected: false},
+		{name: "not specified equal to error", err: base, other: base.WithSeverity(SeverityError), expected: false},
+		{name: "error equal to not specified", err: base.WithSeverity(SeverityError), other: base, expected: false},
+		{name: "unknown severity ranks as error above warn", err: base.WithSeverity(Severity(42)), other: base.WithSeverity(SeverityWarn), expected: true},
+		{name: "unknown severity equal to error", err: base.WithSeverity(Severity(42)), other: base.WithSeverity(SeverityError), expected: false},
+		{name: "fatal more severe than unknown severity", err: base.WithSeverity(SeverityFatal), other: base.WithSeverity(Severity(-1)), expected: true},
+		{name: "severity wins over category", err: base.WithSeverity(SeverityWarn).WithCategory("auth"), other: base.WithCategory("validation"), expected: false},
+		{name: "listed category more severe than later category", err: base.WithCategory("auth"), other: base.WithCategory("io"), expected: true},
+		{name: "later category not more severe", err: base.WithCategory("io"), other: base.WithCategory("auth"), expected: false},
+		{name: "listed category more severe than unlisted", err: base.WithCategory("io"), other: base.WithCategory("validation"), expected: true},
+		{name: "unlisted category equal to no category", err: base.WithCategory("validation"), other: base, expected: false},
+		{name: "category wins over http status", err: base.WithCategory("auth").WithHTTPStatus(400), other: base.WithHTTPStatus(503), expected: true},
+		{name: "5xx more severe than 4xx", err: base.WithHTTPStatus(503), other: base.WithHTTPStatus(404), expected: true},
+		{name: "4xx not more severe than 5xx", err: base.WithHTTPStatus(400), other: base.WithHTTPStatus(500), expected: false},
+		{name: "same http status class", err: base.WithHTTPStatus(503), other: base.WithHTTPStatus(500), expected: false},
+		{name: "http status more severe than none", err: base.WithHTTPStatus(400), other: base, expected: true},
+		{name: "nil other", err: base, other: nil, expected: true},
+	}
+	for _, tc := range testCases {
+		if actual := tc.err.MoreSevereThan(tc.other); actual != tc.expected {
+			t.Errorf("%s test failed: (expected: %t) (actual: %t)", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestSetCategoryPrecedenceCleared(t *testing.T) {
+	SetCategoryPrecedence("auth")
+	SetCategoryPrecedence()
+	err := NewRichError("TestCode", "test message")
+	if err.WithCategory("auth").MoreSevereThan(err.WithCategory("io")) {
+		t.Error("categories should not decide the comparison without a precedence")
+	}
+}
+
+func canonicalTestError() RichError {
+	return NewRichError("TestCode", "test message").
+		WithStack(0).
+		WithTags([]string{"zeta", "alpha"}).
+		AddMetaData("b", 2).
+		AddMetaData("a", 1)
+}
+
+func TestCanonicalString(t *testing.T) {
+	first := canonicalTestError()
+	time.Sleep(time.Millisecond)
+	second := canonicalTestError()
+	if first.GetOccurredAt().Equal(second.GetOccurredAt()) {
+		t.Fatal("test errors should have different occurred at times")
+	}
+	if first.CanonicalString() != second.CanonicalString() {
+		t.Errorf("canonical strings differ: (first: %s) (second: %s)", first.CanonicalString(), second.CanonicalString())
+	}
+	expectedOutput := "CODE: TestCode\nMESSAGE: test message\nTAGS: alpha, zeta\nMETADATA: a=1, b=2\nTOP_FRAME: richerror_test.go:" + first.GetLineNumber() + " errors.canonicalTestError"
+	if first.CanonicalString() != expectedOutput {
+		t.Errorf("canonical string not expected: (expected: %s) (actual: %s)", expectedOutput, first.CanonicalString())
+	}
+	if strings.Join(first.GetTags(), ",") != "zeta,alpha" {
+		t.Errorf("canonical string should not reorder the error tags: %v", first.GetTags())
+	}
+}
+
+func TestHTTPStatusFromError(t *testing.T) {
+	type httpStatusTestCase struct {
+		name           string
+		err            error
+		expectedStatus int
+	}
+	notFoundErr := NewRichError("NotFound", "not found").WithHTTPStatus(http.StatusNotFound)
+	testCases := []httpStatusTestCase{
+		{
+			name:           "nil error",
+			err:            nil,
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "plain error",
+			err:            goerrors.New("plain error"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "rich error without status",
+			err:            NewRichError("TestCode", "test message"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "rich error with status",
+			err:            notFoundErr,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "rich error wrapped by fmt.Errorf",
+			err:            fmt.Errorf("lookup failed: %w", notFoundErr),
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "rich error as inner error",
+			err:            NewRichError("Outer", "outer").AddError(notFoundErr),
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "outer status wins",
+			err:            NewRichError("Outer", "outer").WithHTTPStatus(http.StatusConflict).AddError(notFoundErr),
+			expectedStatus: http.StatusConflict,
+		},
+	}
+	for _, test := range testCases {
+		status := HTTPStatusFromError(test.err)
+		if status != test.expectedStatus {
+			t.Errorf("%s test failed: status not expected: (expected: %d) (actual: %d)", test.name, test.expectedStatus, status)
+		}
+	}
+}
+
+func TestLogValue(t *testing.T) {
+	err := NewRichError("OuterCode", "outer message").
+		WithStack(0).
+		AddTag("database").
+		AddMetaData("userId", 42).
+		AddError(NewRichError("InnerCode", "inner message")).
+		AddError(goerrors.New("plain error"))
+	var logBuffer bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logBuffer, nil))
+	logger.Error("request failed", slog.Any("err", err))
+	var output struct {
+		Err struct {
+			Code     string   `json:"code"`
+			Message  string   `json:"message"`
+			Severity string   `json:"severity"`
+			Source   string   `json:"source"`
+			Line     string   `json:"line"`
+			Tags     []string `json:"tags"`
+			MetaData struct {
+				UserID int `json:"userId"`
+			} `json:"metaData"`
+			InnerErrors map[string]struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"innerErrors"`
+		} `json:"err"`
+	}
+	if unmarshalErr := json.Unmarshal(logBuffer.Bytes(), &output); unmarshalErr != nil {
+		t.Fatalf("failed to parse log output: %s", unmarshalErr.Error())
+	}
+	if output.Err.Code != "OuterCode" || output.Err.Message != "outer message" || output.Err.Severity != "error" {
+		t.Errorf("logged code, message or severity not expected: %s", logBuffer.String())
+	}
+	if output.Err.Source != err.GetSource() || output.Err.Line != err.GetLineNumber() {
+		t.Errorf("logged source not expected: %s", logBuffer.String())
+	}
+	if len(output.Err.Tags) != 1 || output.Err.Tags[0] != "database" || output.Err.MetaData.UserID != 42 {
+		t.Errorf("logged tags or metadata not expected: %s", logBuffer.String())
+	}
+	if output.Err.InnerErrors["0"].Code != "InnerCode" || output.Err.InnerErrors["1"].Message != "plain error" {
+		t.Errorf("logged inner errors not expected: %s", logBuffer.String())
+	}
+}
+
+func TestLogValueCauseAndRelatedErrors(t *testing.T) {
+	err := NewRichError("OuterCode", "outer message").
+		WithCause(NewRichError("CauseCode", "cause message").AddMetaData("password", "hunter2")).
+		AddRelatedError("triggered-by", NewRichError("RelatedCode", "related message")).
+		AddRelatedError("blocked-on", goerrors.New("plain error")).
+		WithRedactedKeys("password")
+	var logBuffer bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logBuffer, nil)).Error("request failed", slog.Any("err", err))
+	var output struct {
+		Err struct {
+			Cause struct {
+				Code     string `json:"code"`
+				MetaData struct {
+					Password string `json:"password"`
+				} `json:"metaData"`
+			} `json:"cause"`
+			RelatedErrors map[string]struct {
+				Relation string `json:"relation"`
+				Error    struct {
+					Code    string `json:"code"`
+					Message string `json:"message"`
+				} `json:"error"`
+			} `json:"relatedErrors"`
+		} `json:"err"`
+	}
+	if unmarshalErr := json.Unmarshal(logBuffer.Bytes(), &output); unmarshalErr != nil {
+		t.Fatalf("failed to parse log output: %s", unmarshalErr.Error())
+	}
+	if output.Err.Cause.Code != "CauseCode" || output.Err.Cause.MetaData.Password != RedactedValue {
+		t.Errorf("logged cause not expected: %s", logBuffer.String())
+	}
+	triggeredBy, blockedOn := output.Err.RelatedErrors["0"], output.Err.RelatedErrors["1"]
+	if triggeredBy.Relation != "triggered-by" || triggeredBy.Error.Code != "RelatedCode" || blockedOn.Relation != "blocked-on" || blockedOn.Error.Message != "plain error" {
+		t.Errorf("logged related errors not expected: %s", logBuffer.String())
+	}
+}
+
+func TestLogValueSelfReferentialError(t *testing.T) {
+	err := NewRichError("CycleCode", "cycle message").AddError(goerrors.New("placeholder error"))
+	err.GetErrors()[0] = err
+	var logBuffer bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logBuffer, nil)).Error("request failed", slog.Any("err", err))
+	if !strings.Contains(logBuffer.String(), cycleDetectedMessage) {
+		t.Errorf("self referential error log should report the cycle (expected: %s) (actual: %s)", cycleDetectedMessage, logBuffer.String())
+	}
+}
+
+func TestAddBreadcrumb(t *testing.T) {
+	before := time.Now().UTC()
+	base := NewRichError("TestCode", "test message").
+		AddBreadcrumb("opened cart", nil).
+		AddBreadcrumb("applied coupon", map[string]interface{}{"coupon": "SAVE10"})
+	err := base.AddBreadcrumb("submitted payment", nil)
+	otherErr := base.AddBreadcrumb("cancelled order", nil)
+	after := time.Now().UTC()
+	breadcrumbs := err.GetBreadcrumbs()
+	expectedMessages := []string{"opened cart", "applied coupon", "submitted payment"}
+	if len(breadcrumbs) != len(expectedMessages) {
+		t.Fatalf("breadcrumbs not expected: %v", breadcrumbs)
+	}
+	for i, breadcrumb := range breadcrumbs {
+		if breadcrumb.Message != expectedMessages[i] {
+			t.Errorf("breadcrumb %d message not expected: (expected: %s) (actual: %s)", i, expectedMessages[i], breadcrumb.Message)
+		}
+		if breadcrumb.Timestamp.Before(before) || breadcrumb.Timestamp.After(after) {
+			t.Errorf("breadcrumb %d timestamp not expected: %s", i, breadcrumb.Timestamp)
+		}
+		if i > 0 && breadcrumb.Timestamp.Before(breadcrumbs[i-1].Timestamp) {
+			t.Errorf("breadcrumb %d timestamp is before the previous breadcrumb", i)
+		}
+	}
+	if otherErr.GetBreadcrumbs()[2].Message != "cancelled order" {
+		t.Errorf("breadcrumbs of derived errors should not be shared: %v", otherErr.GetBreadcrumbs())
+	}
+	if !strings.Contains(err.ToString(FullOutputFormatted), "BREADCRUMBS:") || !strings.Contains(err.ToString(FullOutputFormatted), "applied coupon") {
+		t.Errorf("breadcrumbs not found in full output: %s", err.ToString(FullOutputFormatted))
+	}
+	if !strings.Contains(err.ToString(JSONInlineOutput), `"breadcrumbs":[`) || !strings.Contains(err.ToString(JSONInlineOutput), `"coupon":"SAVE10"`) {
+		t.Errorf("breadcrumbs not found in json output: %s", err.ToString(JSONInlineOutput))
+	}
+}
+
+func TestNewRichErrorWithOptions(t *testing.T) {
+	innerErr := goerrors.New("inner error")
+	expectedErr := NewRichError("TestCode", "test message").WithStack(0)
+	err := NewRichErrorWithOptions("TestCode", "test message",
+		WithTagsOption("a", "b"),
+		WithMetaDataOption(map[string]interface{}{"key": "value"}),
+		WithErrorsOption(innerErr),
+		WithStackOption(0),
+		WithTagsOption("c"),
+	)
+	if strings.Join(err.GetTags(), ",") != "a,b,c" {
+		t.Errorf("tags not expected: (expected: a,b,c) (actual: %v)", err.GetTags())
+	}
+	if value, _ := err.GetMetaDataItem("key"); value != "value" {
+		t.Errorf("metadata not expected: %v", err.GetMetaData())
+	}
+	if len(err.GetErrors()) != 1 || err.GetErrors()[0] != innerErr {
+		t.Errorf("inner errors not expected: %v", err.GetErrors())
+	}
+	if err.GetSource() != expectedErr.GetSource() || err.GetFunction() != expectedErr.GetFunction() {
+		t.Errorf("stack option should capture the caller: (expected: %s %s) (actual: %s %s)", expectedErr.GetSource(), expectedErr.GetFunction(), err.GetSource(), err.GetFunction())
+	}
+}
+
+func TestMaxBreadcrumbs(t *testing.T) {
+	SetGlobalMaxBreadcrumbs(3)
+	defer SetGlobalMaxBreadcrumbs(0)
+	err := NewRichError("TestCode", "test message")
+	for i := 1; i <= 5; i++ {
+		err = err.AddBreadcrumb(fmt.Sprintf("step %d", i), nil)
+	}
+	breadcrumbs := err.GetBreadcrumbs()
+	expectedMessages := []string{"step 3", "step 4", "step 5"}
+	if len(breadcrumbs) != len(expectedMessages) {
+		t.Fatalf("breadcrumbs not expected: %v", breadcrumbs)
+	}
+	for i, breadcrumb := range breadcrumbs {
+		if breadcrumb.Message != expectedMessages[i] {
+			t.Errorf("breadcrumb %d message not expected: (expected: %s) (actual: %s)", i, expectedMessages[i], breadcrumb.Message)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	prototype := NewRichError("TestCode", "test message").
+		WithStack(0).
+		WithTags([]string{"a", "b"}).
+		AddMetaData("key", "value").
+		AddError(goerrors.New("inner error"))
+	clone := prototype.Clone()
+	clone.GetTags()[0] = "changed"
+	clone.GetStack()[0].Line = -1
+	clone.GetErrors()[0] = goerrors.New("changed")
+	clone.GetMetaData()["key"] = "changed"
+	if prototype.GetTags()[0] != "a" {
+		t.Errorf("changing the clone tags changed the prototype: %v", prototype.GetTags())
+	}
+	if prototype.GetStack()[0].Line == -1 {
+		t.Error("changing the clone stack changed the prototype")
+	}
+	if prototype.GetErrors()[0].Error() != "inner error" {
+		t.Errorf("changing the clone inner errors changed the prototype: %v", prototype.GetErrors())
+	}
+	if value, _ := prototype.GetMetaDataItem("key"); value != "value" {
+		t.Errorf("changing the clone metadata changed the prototype: %v", prototype.GetMetaData())
+	}
+	if clone.GetErrorCode() != prototype.GetErrorCode() || !clone.GetOccurredAt().Equal(prototype.GetOccurredAt()) {
+		t.Error("clone should have the same code and occurred at time as the prototype")
+	}
+}
+
+func TestRemoveMetaData(t *testing.T) {
+	err := NewRichError("TestCode", "test message").AddMetaData("password", "hunter2").AddMetaData("userId", 42)
+	sanitizedErr := err.RemoveMetaData("password").RemoveMetaData("missing")
+	if _, ok := sanitizedErr.GetMetaDataItem("password"); ok {
+		t.Error("removed metadata key is still present")
+	}
+	if _, ok := sanitizedErr.GetMetaDataItem("userId"); !ok {
+		t.Error("metadata key that was not removed is missing")
+	}
+	if _, ok := err.GetMetaDataItem("password"); !ok {
+		t.Error("removing metadata changed the original error")
+	}
+}
+
+func TestToMetricLine(t *testing.T) {
+	SetGlobalMetricLabelKeys("component", "region")
+	defer SetGlobalMetricLabelKeys()
+	err := NewRichError("PaymentFailed", "payment for order 12345 failed").
+		WithSeverity(SeverityWarn).
+		AddMetaData("component", "billing").
+		AddMetaData("orderId", 12345).
+		AddMetaData("userEmail", "user@example.com")
+	expectedLine := `richerror code="PaymentFailed" severity="warn" component="billing"`
+	if err.ToMetricLine() != expectedLine {
+		t.Errorf("metric line not expected: (expected: %s) (actual: %s)", expectedLine, err.ToMetricLine())
+	}
+	for _, excluded := range []string{"12345", "user@example.com", "payment for order"} {
+		if strings.Contains(err.ToMetricLine(), excluded) {
+			t.Errorf("metric line contains high cardinality value %s: %s", excluded, err.ToMetricLine())
+		}
+	}
+}
+
+func TestAddRelatedError(t *testing.T) {
+	lockErr := NewRichError("LockHeld", "lock is held by another process")
+	err := NewRichError("UpdateFailed", "update failed").
+		AddRelatedError("blocked-on", lockErr).
+		AddRelatedError("triggered-by", goerrors.New("scheduled job")).
+		AddRelatedError("caused-by", nil)
+	relatedErrors := err.GetRelatedErrors()
+	if len(relatedErrors) != 2 {
+		t.Fatalf("related errors not expected: %v", relatedErrors)
+	}
+	if relatedErrors[0].Relation != "blocked-on" || relatedErrors[0].Err.(ReadOnlyRichError).GetErrorCode() != "LockHeld" {
+		t.Errorf("first related error not expected: %v", relatedErrors[0])
+	}
+	if len(err.GetErrors()) != 0 || goerrors.Unwrap(err) != nil {
+		t.Error("related errors should not be inner errors")
+	}
+	if !strings.Contains(err.ToString(FullOutputFormatted), "RELATED ERRORS:") || !strings.Contains(err.ToString(FullOutputFormatted), "(triggered-by): scheduled job") {
+		t.Errorf("related errors not found in full output: %s", err.ToString(FullOutputFormatted))
+	}
+	reloadedErr, unmarshalErr := UnmarshalRichError([]byte(err.ToString(JSONOutput)))
+	if unmarshalErr != nil {
+		t.Fatalf("failed to unmarshal error: %s", unmarshalErr.Error())
+	}
+	reloadedRelatedErrors := reloadedErr.GetRelatedErrors()
+	if len(reloadedRelatedErrors) != 2 || reloadedRelatedErrors[0].Relation != "blocked-on" || reloadedRelatedErrors[1].Err.Error() != "scheduled job" {
+		t.Errorf("reloaded related errors not expected: %v", reloadedRelatedErrors)
+	}
+	if reloadedLockErr, ok := reloadedRelatedErrors[0].Err.(ReadOnlyRichError); !ok || reloadedLockErr.GetErrorCode() != "LockHeld" {
+		t.Errorf("reloaded related rich error not expected: %v", reloadedRelatedErrors[0].Err)
+	}
+}
+
+func TestRedactedKeys(t *testing.T) {
+	innerErr := NewRichError("InnerCode", "inner message").AddMetaData("ssn", "123-45-6789")
+	err := NewRichError("TestCode", "test message").
+		AddMetaData("Password", "hunter2").
+		AddMetaData("userId", 42).
+		AddError(innerErr).
+		WithRedactedKeys("password", "ssn")
+	outputs := map[string]string{"json": err.ToString(JSONOutput)}
+	for _, format := range []RichErrorOutputFormat{DetailedOutput, FullOutputFormatted, FullOutputInline, ShortDetailedOutput, ShortOutput} {
+		outputs[format.String()] = err.ToString(format)
+	}
+	for name, output := range outputs {
+		if strings.Contains(output, "hunter2") || strings.Contains(output, "123-45-6789") {
+			t.Errorf("redacted value found in %s output: %s", name, output)
+		}
+	}
+	if !strings.Contains(err.ToString(FullOutputFormatted), "Password: "+RedactedValue) || !strings.Contains(err.ToString(FullOutputFormatted), "userId: 42") {
+		t.Errorf("redacted output not expected: %s", err.ToString(FullOutputFormatted))
+	}
+	if value, _ := err.GetMetaDataItem("Password"); value != "hunter2" {
+		t.Errorf("raw metadata value should remain available in process: %v", value)
+	}
+	redactedErr := err.RedactMetaData()
+	if value, _ := redactedErr.GetMetaDataItem("Password"); value != RedactedValue {
+		t.Errorf("metadata value should be permanently redacted: %v", value)
+	}
+	redactedInnerErr := redactedErr.GetErrors()[0].(ReadOnlyRichError)
+	if value, _ := redactedInnerErr.GetMetaDataItem("ssn"); value != RedactedValue {
+		t.Errorf("inner metadata value should be permanently redacted: %v", value)
+	}
+	if value, _ := innerErr.GetMetaDataItem("ssn"); value != "123-45-6789" {
+		t.Errorf("redacting should not modify the original inner error: %v", value)
+	}
+}
+
+func TestRedactMetaDataCauseAndRelatedErrors(t *testing.T) {
+	causeErr := NewRichError("CauseCode", "cause message").AddMetaData("ssn", "123-45-6789")
+	relatedErr := NewRichError("RelatedCode", "related message").AddMetaData("password", "hunter2")
+	err := NewRichError("TestCode", "test message").
+		WithCause(causeErr).
+		AddRelatedError("triggered-by", relatedErr).
+		AddRelatedError("blocked-on", goerrors.New("plain error")).
+		WithRedactedKeys("password", "ssn").
+		RedactMetaData()
+	if value, _ := err.GetCause().(ReadOnlyRichError).GetMetaDataItem("ssn"); value != RedactedValue {
+		t.Errorf("cause metadata value should be permanently redacted: %v", value)
+	}
+	relatedErrs := err.GetRelatedErrors()
+	if value, _ := relatedErrs[0].Err.(ReadOnlyRichError).GetMetaDataItem("password"); value != RedactedValue || relatedErrs[0].Relation != "triggered-by" {
+		t.Errorf("related error metadata value should be permanently redacted: %s %v", relatedErrs[0].Relation, value)
+	}
+	if relatedErrs[1].Err.Error() != "plain error" {
+		t.Errorf("related errors that are not rich errors should be kept: %v", relatedErrs[1].Err)
+	}
+	if value, _ := causeErr.GetMetaDataItem("ssn"); value != "123-45-6789" {
+		t.Errorf("redacting should not modify the original cause: %v", value)
+	}
+	if value, _ := relatedErr.GetMetaDataItem("password"); value != "hunter2" {
+		t.Errorf("redacting should not modify the original related error: %v", value)
+	}
+}
+
+func TestGlobalRedactedKeys(t *testing.T) {
+	SetGlobalRedactedKeys("token")
+	defer SetGlobalRedactedKeys()
+	err := NewRichError("TestCode", "test message").AddMetaData("token", "abc123")
+	if strings.Contains(err.ToString(FullOutputFormatted), "abc123") {
+		t.Errorf("globally redacted value found in output: %s", err.ToString(FullOutputFormatted))
+	}
+}
+
+func TestSetClock(t *testing.T) {
+	frozenTime := time.Date(2021, time.March, 14, 15, 9, 26, 0, time.UTC)
+	SetClock(func() time.Time { return frozenTime })
+	defer SetClock(nil)
+	err := NewRichError("TestCode", "test message").AddBreadcrumb("step", nil)
+	if !err.GetOccurredAt().Equal(frozenTime) {
+		t.Errorf("SetClock test failed: occurred at not expected (expected: %s) (actual: %s)", frozenTime, err.GetOccurredAt())
+	}
+	if breadcrumbTime := err.GetBreadcrumbs()[0].Timestamp; !breadcrumbTime.Equal(frozenTime) {
+		t.Errorf("SetClock test failed: breadcrumb timestamp not expected (expected: %s) (actual: %s)", frozenTime, breadcrumbTime)
+	}
+}
+
+func TestHasTag(t *testing.T) {
+	type testCase struct {
+		name     string
+		tag      string
+		expected bool
+	}
+	err := NewRichError("TestCode", "test message").WithTags([]string{"Retryable", " database "})
+	testCases := []testCase{
+		{name: "exact match", tag: "Retryable", expected: true},
+		{name: "case insensitive match", tag: "retryable", expected: true},
+		{name: "whitespace ignored", tag: "DATABASE", expected: true},
+		{name: "missing tag", tag: "network", expected: false},
+	}
+	for _, tc := range testCases {
+		if actual := err.HasTag(tc.tag); actual != tc.expected {
+			t.Errorf("%s test failed: HasTag result not expected (expected: %t) (actual: %t)", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestCustomOutputWithoutFunction(t *testing.T) {
+	SetCustomOutputFunction(nil)
+	SetErrorOutputFormat(CustomOutput)
+	defer SetErrorOutputFormat(FullOutputFormatted)
+	err := NewRichError("TestCode", "test message")
+	expected := err.ToString(FullOutputFormatted)
+	if actual := err.Error(); actual != expected {
+		t.Errorf("custom output without function test failed: output not expected (expected: %s) (actual: %s)", expected, actual)
+	}
+	if actual := err.ToCustomString(nil); actual != expected {
+		t.Errorf("custom output without function test failed: ToCustomString output not expected (expected: %s) (actual: %s)", expected, actual)
+	}
+}
+
+func TestFullOutputRecursive(t *testing.T) {
+	deepestErr := NewRichError("DeepestCode", "deepest message").WithStack(0)
+	innerErr := NewRichError("InnerCode", "inner message").AddError(deepestErr)
+	err := NewRichError("OuterCode", "outer message").AddError(innerErr)
+	output := err.ToString(FullOutputRecursive)
+	for _, expected := range []string{"ERRCODE: OuterCode", "\n\t\tERRCODE: InnerCode", "\n\t\t\t\tERRCODE: DeepestCode", "\n\t\t\t\tSTACK: "} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("recursive output test failed: output missing expected section (expected: %q) (actual: %s)", expected, output)
+		}
+	}
+}
+
+func TestFullOutputRecursiveCycle(t *testing.T) {
+	err := NewRichError("CycleCode", "cycle message").AddError(goerrors.New("placeholder error"))
+	err.GetErrors()[0] = err
+	output := err.ToString(FullOutputRecursive)
+	if !strings.Contains(output, cycleDetectedMessage) {
+		t.Errorf("recursive output cycle test failed: cycle not reported (expected: %s) (actual: %s)", cycleDetectedMessage, output)
+	}
+}
+
+func TestSelfReferentialErrorOutput(t *testing.T) {
+	type testCase struct {
+		name   string
+		format RichErrorOutputFormat
+	}
+	directErr := NewRichError("CycleCode", "cycle message").AddError(goerrors.New("placeholder error"))
+	directErr.GetErrors()[0] = directErr
+	outerErr := NewRichError("OuterCode", "outer message").AddError(goerrors.New("placeholder error"))
+	transitiveErr := NewRichError("InnerCode", "inner message").AddError(outerErr)
+	outerErr.GetErrors()[0] = transitiveErr
+	redactedErr := NewRichError("RedactedCode", "redacted message").WithRedactedKeys("password").AddError(goerrors.New("placeholder error"))
+	redactedErr.GetErrors()[0] = redactedErr
+	testCases := []testCase{
+		{name: "full formatted", format: FullOutputFormatted},
+		{name: "full inline", format: FullOutputInline},
+		{name: "full recursive", format: FullOutputRecursive},
+		{name: "json", format: JSONOutput},
+	}
+	for _, tc := range testCases {
+		for _, err := range []RichError{directErr, outerErr, redactedErr} {
+			output := err.ToString(tc.format)
+			if !strings.Contains(output, cycleDetectedMessage) || strings.Contains(output, truncatedMessage) {
+				t.Errorf("%s test failed: cycle not reported for %s (expected: %s) (actual: %s)", tc.name, err.GetErrorCode(), cycleDetectedMessage, output)
+			}
+		}
+	}
+}
+
+func TestCopiesSharingInnerErrorsOutput(t *testing.T) {
+	baseErr := NewRichError("BaseCode", "base message").
+		AddError(goerrors.New("first inner error")).
+		AddError(goerrors.New("second inner error")).
+		AddError(goerrors.New("third inner error"))
+	err := baseErr.AddTag("a").WithCause(baseErr.AddTag("b"))
+	for _, format := range []RichErrorOutputFormat{FullOutputFormatted, FullOutputRecursive, JSONOutput} {
+		if output := err.ToString(format); strings.Contains(output, cycleDetectedMessage) {
+			t.Errorf("copies sharing inner errors test failed: cycle reported for output format %s (actual: %s)", format, output)
+		}
+	}
+}
+
+func TestSetGlobalMaxRenderDepth(t *testing.T) {
+	SetGlobalMaxRenderDepth(2)
+	defer SetGlobalMaxRenderDepth(0)
+	err := NewRichError("DepthCode3", "depth 3")
+	for i := 2; i >= 0; i-- {
+		err = NewRichError(fmt.Sprintf("DepthCode%d", i), fmt.Sprintf("depth %d", i)).AddError(err)
+	}
+	output := err.ToString(FullOutputFormatted)
+	if !strings.Contains(output, "DepthCode1") || strings.Contains(output, "DepthCode2") || !strings.Contains(output, truncatedMessage) {
+		t.Errorf("max render depth test failed: output not truncated at depth 2 (expected: %s) (actual: %s)", truncatedMessage, output)
+	}
+}
+
+func TestGetRootError(t *testing.T) {
+	rootErr := goerrors.New("root cause")
+	middleErr := NewRichError("MiddleCode", "middle message").AddError(rootErr)
+	err := NewRichError("OuterCode", "outer message").AddError(middleErr).AddError(goerrors.New("second inner error"))
+	if actual := err.GetRootError(); actual != rootErr {
+		t.Errorf("GetRootError test failed: root error not expected (expected: %s) (actual: %s)", rootErr, actual)
+	}
+	noInnerErr := NewRichError("NoInnerCode", "no inner errors")
+	if actual, ok := noInnerErr.GetRootError().(ReadOnlyRichError); !ok || actual.GetErrorCode() != "NoInnerCode" {
+		t.Errorf("GetRootError test failed: error without inner errors should be its own root (expected: %s) (actual: %v)", "NoInnerCode", actual)
+	}
+	cycleErr := NewRichError("CycleCode", "cycle message").AddError(goerrors.New("placeholder error"))
+	cycleErr.GetErrors()[0] = cycleErr
+	if actual := cycleErr.GetRootError(); actual == nil {
+		t.Errorf("GetRootError test failed: self referential error should return a root")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	type testCase struct {
+		name          string
+		stopAt        string
+		expectedCodes string
+	}
+	innerErr := NewRichError("InnerCode", "inner message").AddError(NewRichError("DeepestCode", "deepest message"))
+	err := NewRichError("OuterCode", "outer message").
+		AddError(innerErr).
+		AddError(goerrors.New("plain error")).
+		AddError(NewRichError("SiblingCode", "sibling message"))
+	testCases := []testCase{
+		{name: "full traversal", expectedCodes: "OuterCode:0,InnerCode:1,DeepestCode:2,SiblingCode:1"},
+		{name: "stop early", stopAt: "DeepestCode", expectedCodes: "OuterCode:0,InnerCode:1,DeepestCode:2"},
+	}
+	for _, tc := range testCases {
+		visited := make([]string, 0)
+		err.Walk(func(err ReadOnlyRichError, depth int) bool {
+			visited = append(visited, fmt.Sprintf("%s:%d", err.GetErrorCode(), depth))
+			return err.GetErrorCode() != tc.stopAt
+		})
+		if actual := strings.Join(visited, ","); actual != tc.expectedCodes {
+			t.Errorf("%s test failed: visited errors not expected (expected: %s) (actual: %s)", tc.name, tc.expectedCodes, actual)
+		}
+	}
+}
+
+func TestWalkCopiesSharingInnerErrors(t *testing.T) {
+	baseErr := NewRichError("BaseCode", "base message").
+		AddError(NewRichError("InnerCode", "inner message")).
+		AddError(goerrors.New("second inner error")).
+		AddError(goerrors.New("third inner error"))
+	err := baseErr.AddTag("a").WithCause(baseErr.AddTag("b"))
+	visited := make([]string, 0)
+	err.Walk(func(err ReadOnlyRichError, depth int) bool {
+		visited = append(visited, fmt.Sprintf("%s:%d", err.GetErrorCode(), depth))
+		return true
+	})
+	expectedCodes := "BaseCode:0,BaseCode:1,InnerCode:2,InnerCode:1"
+	if actual := strings.Join(visited, ","); actual != expectedCodes {
+		t.Errorf("walk copies test failed: visited errors not expected (expected: %s) (actual: %s)", expectedCodes, actual)
+	}
+	if actual := err.GetAllTags(); fmt.Sprint(actual) != "[a b]" {
+		t.Errorf("walk copies test failed: all tags not expected (expected: %s) (actual: %v)", "[a b]", actual)
+	}
+	if actual, ok := err.GetRootError().(ReadOnlyRichError); !ok || actual.GetErrorCode() != "InnerCode" {
+		t.Errorf("walk copies test failed: root error not expected (expected: %s) (actual: %v)", "InnerCode", err.GetRootError())
+	}
+}
+
+func TestColorOutput(t *testing.T) {
+	err := NewRichError("TestCode", "test message").AddSource("source")
+	SetGlobalColorEnabled(false)
+	if actual, expected := err.ToString(ColorOutput), err.ToString(FullOutputFormatted); actual != expected {
+		t.Errorf("color output test failed: output with colors disabled not expected (expected: %s) (actual: %s)", expected, actual)
+	}
+	SetGlobalColorEnabled(true)
+	defer SetGlobalColorEnabled(false)
+	output := err.ToString(ColorOutput)
+	for _, expected := range []string{ansiDim + "TIMESTAMP: ", ansiBold + ansiRed + "ERRCODE: " + ansiReset + "TestCode", ansiCyan + "SOURCE: " + ansiReset + "source"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("color output test failed: output missing colored section (expected: %q) (actual: %q)", expected, output)
+		}
+	}
+	for _, format := range []RichErrorOutputFormat{FullOutputInline, JSONOutput} {
+		if strings.Contains(err.ToString(format), "\x1b[") {
+			t.Errorf("color output test failed: %s output should not contain color codes: %q", format.String(), err.ToString(format))
+		}
+	}
+}
+
+func TestLogfmtOutput(t *testing.T) {
+	occurredAt := time.Date(2021, time.March, 14, 15, 9, 26, 0, time.UTC)
+	SetClock(func() time.Time { return occurredAt })
+	defer SetClock(nil)
+	err := NewRichError("TestCode", `message with "quotes"`).
+		AddSource("source.go").
+		AddLineNumber("42").
+		WithTags([]string{"db", "retryable"}).
+		AddMetaData("user_id", 123).
+		AddMetaData("query", "select 1")
+	expected := `time=2021-03-14T15:09:26Z code=TestCode msg="message with \"quotes\"" source=source.go line=42 tags=db,retryable meta.query="select 1" meta.user_id=123`
+	if actual := err.ToString(LogfmtOutput); actual != expected {
+		t.Errorf("logfmt output test failed: output not expected (expected: %s) (actual: %s)", expected, actual)
+	}
+	err = NewRichError("TestCode", "test message").
+		SetTimestampFormat("2006-01-02 15:04").
+		AddMetaData("user id", 123).
+		AddMetaData(`a="b"`, "c")
+	expected = `time="2021-03-14 15:09" code=TestCode msg="test message" meta.a__b_=c meta.user_id=123`
+	if actual := err.ToString(LogfmtOutput); actual != expected {
+		t.Errorf("logfmt output test failed: timestamp format and metadata keys not expected (expected: %s) (actual: %s)", expected, actual)
+	}
+}
+
+func TestSetOutputTemplate(t *testing.T) {
+	type testCase struct {
+		name        string
+		tmpl        string
+		expected    string
+		expectError bool
+	}
+	defer SetCustomOutputFunction(nil)
+	err := NewRichError("TestCode", "test message").WithTags([]string{"tag1"})
+	testCases := []testCase{
+		{name: "valid template", tmpl: "{{.GetErrorCode}}: {{.GetErrorMessage}} {{.GetTags}}", expected: "TestCode: test message [tag1]"},
+		{name: "invalid template", tmpl: "{{.GetErrorCode", expectError: true},
+		{name: "execution failure", tmpl: "{{.MissingField}}", expected: "[output template failed: "},
+	}
+	for _, tc := range testCases {
+		SetCustomOutputFunction(nil)
+		setErr := SetOutputTemplate(tc.tmpl)
+		if tc.expectError {
+			if setErr == nil {
+				t.Errorf("%s test failed: expected an error for template %s", tc.name, tc.tmpl)
+			}
+			continue
+		}
+		if setErr != nil {
+			t.Errorf("%s test failed: unexpected error: %s", tc.name, setErr.Error())
+			continue
+		}
+		if actual := err.ToString(CustomOutput); !strings.HasPrefix(actual, tc.expected) {
+			t.Errorf("%s test failed: output not expected (expected: %s) (actual: %s)", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestSetOutputFormat(t *testing.T) {
+	SetErrorOutputFormat(FullOutputFormatted)
+	err := NewRichError("TestCode", "test message")
+	shortErr := err.SetOutputFormat(ShortOutput)
+	if actual, expected := shortErr.Error(), err.ToString(ShortOutput); actual != expected {
+		t.Errorf("per error output format test failed: output not expected (expected: %s) (actual: %s)", expected, actual)
+	}
+	if actual, expected := err.Error(), err.ToString(FullOutputFormatted); actual != expected {
+		t.Errorf("per error output format test failed: original error should use the global format (expected: %s) (actual: %s)", expected, actual)
+	}
+	if actual, expected := shortErr.SetOutputFormat(NotSpecified).Error(), err.ToString(FullOutputFormatted); actual != expected {
+		t.Errorf("per error output format test failed: NotSpecified should restore the global format (expected: %s) (actual: %s)", expected, actual)
+	}
+}
+
+func newBenchmarkError() RichError {
+	innerErr := NewRichError("InnerCode", "inner message").AddMetaData("attempt", 1)
+	return NewRichError("BenchmarkCode", "benchmark message").
+		WithStack(0).
+		WithTags([]string{"tag1", "tag2"}).
+		WithMetaData(map[string]interface{}{"userId": "calvine", "count": 3}).
+		AddError(innerErr)
+}
+
+func BenchmarkFullOutputString(b *testing.B) {
+	err := newBenchmarkError()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = err.ToString(FullOutputFormatted)
+	}
+}
+
+func BenchmarkDetailedOutputString(b *testing.B) {
+	err := newBenchmarkError()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = err.ToString(DetailedOutput)
+	}
+}
+
+func TestOutputBufferReuse(t *testing.T) {
+	err := newBenchmarkError()
+	expected := err.ToString(FullOutputFormatted)
+	var wg sync.WaitGroup
+	outputs := make([]string, 8)
+	for i := range outputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				outputs[i] = err.ToString(FullOutputFormatted)
+				_ = err.ToString(DetailedOutput)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, actual := range outputs {
+		if actual != expected {
+			t.Errorf("buffer reuse test failed: output %d changed after its buffer was recycled (expected: %s) (actual: %s)", i, expected, actual)
+		}
+	}
+}
+
+func TestLazyStack(t *testing.T) {
+	err := NewRichError("TestCode", "test message").WithStack(0)
+	lazy := err.(richError).lazyStack
+	if lazy == nil || lazy.entries != nil {
+		t.Fatal("lazy stack test failed: stack should not be resolved when it is captured")
+	}
+	if !err.HasStack() || lazy.entries != nil {
+		t.Error("lazy stack test failed: HasStack should report the stack without resolving it")
+	}
+	if !strings.HasSuffix(err.GetSource(), "richerror_test.go") || err.GetFunction() != "TestLazyStack" {
+		t.Errorf("lazy stack test failed: location not expected (expected: richerror_test.go TestLazyStack) (actual: %s %s)", err.GetSource(), err.GetFunction())
+	}
+	copiedErr := err.AddTag("copied")
+	stack := copiedErr.GetStack()
+	if len(stack) == 0 || stack[0].Function != "github.com/calvine/richerror/errors.TestLazyStack" {
+		t.Fatalf("lazy stack test failed: resolved stack not expected: %v", stack)
+	}
+	if len(lazy.entries) != len(stack) || &err.GetStack()[0] != &stack[0] {
+		t.Error("lazy stack test failed: resolved stack should be cached and shared by copies of the error")
+	}
+}
+
+func BenchmarkWithStack(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = NewRichError("BenchmarkCode", "benchmark message").WithStack(0)
+	}
+}
+
+func TestWithStackDepth(t *testing.T) {
+	fullStack := NewRichError("TestCode", "test message").WithStack(0).GetStack()
+	err := NewRichError("TestCode", "test message").WithStackDepth(0, 2)
+	stack := err.GetStack()
+	if len(stack) != 3 {
+		t.Fatalf("stack depth test failed: frame count not expected (expected: 3) (actual: %d)", len(stack))
+	}
+	if stack[0].Function != fullStack[0].Function || stack[1].Function != fullStack[1].Function {
+		t.Errorf("stack depth test failed: retained frames not expected (expected: %v) (actual: %v)", fullStack[:2], stack[:2])
+	}
+	expectedFunction := fmt.Sprintf("... %d frames omitted", len(fullStack)-2)
+	if stack[2].Depth != 2 || stack[2].Function != expectedFunction {
+		t.Errorf("stack depth test failed: omitted frames entry not expected (expected: %s at depth 2) (actual: %s at depth %d)", expectedFunction, stack[2].Function, stack[2].Depth)
+	}
+	if !err.HasValidPCs() {
+		t.Error("stack depth test failed: the omitted frames entry should not invalidate the program counters")
+	}
+	SetMaxStackDepth(3)
+	defer SetMaxStackDepth(0)
+	if actual := len(recursiveStackError(10).GetStack()); actual != 4 {
+		t.Errorf("stack depth test failed: global max stack depth not applied (expected: 4 frames) (actual: %d)", actual)
+	}
+}
+
+func TestSetStackFramePrefilter(t *testing.T) {
+	SetStackFramePrefilter(ExcludeFramesContaining("/runtime/", ".recursiveStackError"))
+	defer SetStackFramePrefilter(nil)
+	err := recursiveStackError(5)
+	for i, frame := range err.GetStack() {
+		if strings.Contains(frame.File, "/runtime/") || strings.HasSuffix(frame.Function, ".recursiveStackError") {
+			t.Errorf("stack frame prefilter test failed: excluded frame captured: %s", frame.String())
+		}
+		if frame.Depth != i {
+			t.Errorf("stack frame prefilter test failed: depth not contiguous (expected: %d) (actual: %d)", i, frame.Depth)
+		}
+	}
+	if err.GetFunction() != "TestSetStackFramePrefilter" {
+		t.Errorf("stack frame prefilter test failed: location should come from the first frame that is not excluded (expected: TestSetStackFramePrefilter) (actual: %s)", err.GetFunction())
+	}
+	SetStackFramePrefilter(ExcludeFramesContaining("/runtime/", ".TestSetStackFramePrefilter"))
+	depthErr := NewRichError("TestCode", "test message").WithStackDepth(0, 1)
+	if stack := depthErr.GetStack(); len(stack) != 1 || stack[0].Function != "testing.tRunner" {
+		t.Errorf("stack frame prefilter test failed: excluded frames should not count towards the max depth (expected: [testing.tRunner]) (actual: %v)", stack)
+	}
+}
+
+func TestStackEntryPointLocation(t *testing.T) {
+	type testCase struct {
+		name    string
+		capture func() (RichError, int)
+	}
+	testCases := []testCase{
+		{
+			name: "WithStack",
+			capture: func() (RichError, int) {
+				_, _, line, _ := runtime.Caller(0)
+				return NewRichError("TestCode", "test message").WithStack(0), line + 1
+			},
+		},
+		{
+			name: "NewRichErrorWithStack",
+			capture: func() (RichError, int) {
+				_, _, line, _ := runtime.Caller(0)
+				return NewRichErrorWithStack("TestCode", "test message", 0), line + 1
+			},
+		},
+	}
+	for _, tc := range testCases {
+		err, expectedLine := tc.capture()
+		if !strings.HasSuffix(err.GetSource(), "richerror_test.go") {
+			t.Errorf("%s test failed: source not expected (expected: richerror_test.go) (actual: %s)", tc.name, err.GetSource())
+		}
+		if err.GetLineNumber() != strconv.Itoa(expectedLine) {
+			t.Errorf("%s test failed: line not expected (expected: %d) (actual: %s)", tc.name, expectedLine, err.GetLineNumber())
+		}
+		if err.GetFunction() != "func1" && err.GetFunction() != "func2" {
+			t.Errorf("%s test failed: function not expected (expected: the test case closure) (actual: %s)", tc.name, err.GetFunction())
+		}
+	}
+}
+
+func TestShortPaths(t *testing.T) {
+	type testCase struct {
+		name     string
+		err      RichError
+		global   bool
+		expected bool
+	}
+	err := NewRichError("TestCode", "test message").WithStack(0)
+	testCases := []testCase{
+		{name: "default", err: err, expected: false},
+		{name: "global", err: err, global: true, expected: true},
+		{name: "per error", err: err.WithShortPaths(true), expected: true},
+		{name: "per error overrides global", err: err.WithShortPaths(false), global: true, expected: false},
+	}
+	defer SetUseShortPaths(false)
+	for _, tc := range testCases {
+		SetUseShortPaths(tc.global)
+		expectedSource := tc.err.GetSource()
+		if tc.expected {
+			expectedSource = "errors/richerror_test.go"
+		}
+		outputs := map[string]string{
+			"full":     tc.err.ToString(FullOutputFormatted),
+			"detailed": tc.err.ToString(DetailedOutput),
+			"logfmt":   tc.err.ToString(LogfmtOutput),
+		}
+		for outputName, output := range outputs {
+			if !strings.Contains(output, expectedSource) || (tc.expected && strings.Contains(output, tc.err.GetSource())) {
+				t.Errorf("%s test failed: %s output source not expected (expected: %s) (actual: %s)", tc.name, outputName, expectedSource, output)
+			}
+		}
+		if !strings.Contains(tc.err.ToString(FullOutputFormatted), fmt.Sprintf("- %s:", expectedSource)) {
+			t.Errorf("%s test failed: stack file not expected (expected: %s) (actual: %s)", tc.name, expectedSource, tc.err.ToString(FullOutputFormatted))
+		}
+		if !strings.Contains(tc.err.ToString(JSONOutput), tc.err.GetSource()) {
+			t.Errorf("%s test failed: JSON output should keep the full path (expected: %s) (actual: %s)", tc.name, tc.err.GetSource(), tc.err.ToString(JSONOutput))
+		}
+	}
+}
+
+func TestCaptureGoroutineID(t *testing.T) {
+	if _, ok := NewRichError("TestCode", "test message").WithStack(0).GetGoroutineID(); ok {
+		t.Error("goroutine id test failed: goroutine id should not be captured by default")
+	}
+	SetCaptureGoroutineID(true)
+	defer SetCaptureGoroutineID(false)
+	err := NewRichError("TestCode", "test message").WithStack(0)
+	goroutineID, ok := err.GetGoroutineID()
+	if !ok || goroutineID != currentGoroutineID() {
+		t.Fatalf("goroutine id test failed: goroutine id not expected (expected: %d) (actual: %d)", currentGoroutineID(), goroutineID)
+	}
+	otherGoroutineErr := make(chan RichError)
+	go func() {
+		otherGoroutineErr <- NewRichError("TestCode", "test message").WithStack(0)
+	}()
+	if otherID, _ := (<-otherGoroutineErr).GetGoroutineID(); otherID == goroutineID || otherID == 0 {
+		t.Errorf("goroutine id test failed: error from another goroutine should have a different goroutine id (actual: %d)", otherID)
+	}
+	expectedSection := fmt.Sprintf("\nGOROUTINE: %d\n", goroutineID)
+	if output := err.ToString(FullOutputFormatted); !strings.Contains(output, expectedSection) {
+		t.Errorf("goroutine id test failed: full output missing goroutine section (expected: %q) (actual: %s)", expectedSection, output)
+	}
+	expectedJSON := fmt.Sprintf(`"goroutineId":%d`, goroutineID)
+	if output := err.ToString(JSONInlineOutput); !strings.Contains(output, expectedJSON) {
+		t.Errorf("goroutine id test failed: JSON output missing goroutine id (expected: %s) (actual: %s)", expectedJSON, output)
+	}
+}
+
+func TestToMap(t *testing.T) {
+	innerErr := NewRichError("InnerCode", "inner message").AddMetaData("password", "hunter2")
+	err := NewRichError("OuterCode", "outer message").
+		WithStack(0).
+		WithTags([]string{"tag1"}).
+		WithRedactedKeys("password").
+		AddMetaData("userId", "calvine").
+		AddError(innerErr).
+		AddError(goerrors.New("plain error"))
+	errMap := err.ToMap()
+	expectedValues := map[string]interface{}{
+		"code":     "OuterCode",
+		"message":  "outer message",
+		"source":   err.GetSource(),
+		"function": "TestToMap",
+		"line":     err.GetLineNumber(),
+		"severity": "error",
+	}
+	for key, expected := range expectedValues {
+		if errMap[key] != expected {
+			t.Errorf("to map test failed: %s not expected (expected: %v) (actual: %v)", key, expected, errMap[key])
+		}
+	}
+	if occurredAt := err.GetOccurredAt().Format(time.RFC3339Nano); errMap["occurredAt"] != occurredAt {
+		t.Errorf("to map test failed: occurredAt not expected (expected: %v) (actual: %v)", occurredAt, errMap["occurredAt"])
+	}
+	if fmt.Sprint(errMap["tags"]) != "[tag1]" || fmt.Sprint(errMap["metaData"]) != "map[userId:calvine]" {
+		t.Errorf("to map test failed: tags and metadata not expected (actual: %v %v)", errMap["tags"], errMap["metaData"])
+	}
+	stack, ok := errMap["stack"].([]map[string]interface{})
+	if !ok || len(stack) != len(err.GetStack()) || stack[0]["function"] != err.GetStack()[0].Function {
+		t.Errorf("to map test failed: stack not expected: %v", errMap["stack"])
+	}
+	innerErrors, ok := errMap["innerErrors"].([]map[string]interface{})
+	if !ok || len(innerErrors) != 2 {
+		t.Fatalf("to map test failed: inner errors not expected: %v", errMap["innerErrors"])
+	}
+	if innerErrors[0]["code"] != "InnerCode" || fmt.Sprint(innerErrors[0]["metaData"]) != fmt.Sprintf("map[password:%s]", RedactedValue) {
+		t.Errorf("to map test failed: inner rich error not expected: %v", innerErrors[0])
+	}
+	if fmt.Sprint(innerErrors[1]) != "map[message:plain error]" {
+		t.Errorf("to map test failed: inner plain error not expected: %v", innerErrors[1])
+	}
+}
+
+func TestToMapMatchesJSON(t *testing.T) {
+	type testCase struct {
+		name string
+		err  RichError
+	}
+	innerErr := NewRichError("InnerCode", "inner message").AddMetaData("password", "hunter2")
+	fullErr := NewRichError("OuterCode", "outer message").
+		WithStack(0).
+		WithTags([]string{"tag1"}).
+		WithRedactedKeys("password").
+		AddMetaData("userId", "calvine").
+		AddError(innerErr).
+		AddError(goerrors.New("plain error")).
+		WithCause(NewRichError("CauseCode", "cause message")).
+		AddRelatedError("compensation", goerrors.New("rollback failed")).
+		AddBreadcrumb("loaded user", map[string]interface{}{"id": 42}).
+		AddBreadcrumb("saved user", nil).
+		WithRetryAfter(1500 * time.Millisecond).
+		WithAction("retry later").
+		WithCategory("database").
+		WithHTTPStatus(503).
+		WithGRPCCode(14).
+		WithRetryable(true)
+	testCases := []testCase{
+		{name: "minimal error", err: NewRichError("TestCode", "test message")},
+		{name: "full error", err: fullErr},
+	}
+	for _, tc := range testCases {
+		jsonData, err := json.Marshal(tc.err)
+		if err != nil {
+			t.Fatalf("%s test failed: unable to marshal error: %s", tc.name, err)
+		}
+		mapData, err := json.Marshal(tc.err.ToMap())
+		if err != nil {
+			t.Fatalf("%s test failed: unable to marshal map: %s", tc.name, err)
+		}
+		var expected, actual interface{}
+		json.Unmarshal(jsonData, &expected)
+		json.Unmarshal(mapData, &actual)
+		if fmt.Sprint(expected) != fmt.Sprint(actual) {
+			t.Errorf("%s test failed: map does not match the JSON output (expected: %s) (actual: %s)", tc.name, jsonData, mapData)
+		}
+	}
+}
+
+func TestWithGRPCCode(t *testing.T) {
+	err := NewRichError("NotFound", "not found")
+	if _, ok := err.GetGRPCCode(); ok {
+		t.Error("grpc code test failed: errors should not have a grpc code by default")
+	}
+	err = err.WithGRPCCode(5)
+	if code, ok := err.GetGRPCCode(); !ok || code != 5 {
+		t.Errorf("grpc code test failed: code not expected (expected: 5) (actual: %d)", code)
+	}
+	if output := err.ToString(JSONInlineOutput); !strings.Contains(output, `"grpcCode":5`) {
+		t.Errorf("grpc code test failed: JSON output missing grpc code: %s", output)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	type testCase struct {
+		name     string
+		other    ReadOnlyRichError
+		expected bool
+	}
+	err := NewRichError("TestCode", "test message").
+		WithTags([]string{"tag1", "tag2"}).
+		WithMetaData(map[string]interface{}{"ids": []int{1, 2}, "user": "calvine"})
+	testCases := []testCase{
+		{name: "same error", other: err, expected: true},
+		{
+			name: "different time, stack and tag order",
+			other: NewRichError("TestCode", "test message").
+				WithStack(0).
+				WithTags([]string{"tag2", "tag1"}).
+				WithMetaData(map[string]interface{}{"user": "calvine", "ids": []int{1, 2}}),
+			expected: true,
+		},
+		{name: "nil error", other: nil, expected: false},
+		{name: "different code", other: NewRichError("OtherCode", "test message"), expected: false},
+		{name: "different message", other: NewRichError("TestCode", "other message"), expected: false},
+		{name: "different source", other: err.AddSource("elsewhere").AddLineNumber("42"), expected: true},
+		{name: "different tags", other: err.AddTag("tag3"), expected: false},
+		{name: "different metadata", other: err.AddMetaData("ids", []int{1, 3}), expected: false},
+		{name: "extra metadata", other: err.AddMetaData("extra", true), expected: false},
+	}
+	for _, tc := range testCases {
+		if actual := err.Equal(tc.other); actual != tc.expected {
+			t.Errorf("%s test failed: Equal result not expected (expected: %t) (actual: %t)", tc.name, tc.expected, actual)
+		}
+	}
+	if !NewRichError("TestCode", "").Equal(NewRichError("TestCode", "").WithTags([]string{}).WithMetaData(map[string]interface{}{})) {
+		t.Error("empty test failed: nil and empty tags and metadata should be equal")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	firstErr := NewRichError("FirstCode", "first failure").WithTags([]string{"database", "retryable"})
+	secondErr := NewRichError("SecondCode", "second failure").WithTags([]string{"retryable", "timeout"})
+	merged := Merge("BatchFailed", "batch failed", firstErr, nil, secondErr, io.EOF)
+	if len(merged.GetErrors()) != 3 {
+		t.Errorf("merge test failed: inner error count not expected (expected: 3) (actual: %d)", len(merged.GetErrors()))
+	}
+	expectedTags := "[database retryable timeout]"
+	if fmt.Sprint(merged.GetTags()) != expectedTags {
+		t.Errorf("merge test failed: tags not expected (expected: %s) (actual: %v)", expectedTags, merged.GetTags())
+	}
+	if merged.GetFunction() != "TestMerge" {
+		t.Errorf("merge test failed: stack should start at the caller of Merge (expected: TestMerge) (actual: %s)", merged.GetFunction())
+	}
+	for _, target := range []error{firstErr, NewRichError("SecondCode", ""), io.EOF, NewRichError("BatchFailed", "")} {
+		if !goerrors.Is(merged, target) {
+			t.Errorf("merge test failed: errors.Is should match every inner error (target: %s)", target.Error())
+		}
+	}
+	if goerrors.Is(merged, io.ErrUnexpectedEOF) {
+		t.Error("merge test failed: errors.Is matched an error that was not merged")
+	}
+}
+
+func TestErrorsIsAs(t *testing.T) {
+	type isAsTestCase struct {
+		name         string
+		err          RichError
+		target       error
+		expectedIs   bool
+		expectedPath string
+	}
+	firstPathErr := &os.PathError{Op: "open", Path: "first", Err: os.ErrNotExist}
+	secondPathErr := &os.PathError{Op: "open", Path: "second", Err: os.ErrPermission}
+	testCases := []isAsTestCase{
+		{name: "first inner error", err: NewRichError("OuterCode", "outer message").AddError(firstPathErr).AddError(io.EOF), target: os.ErrNotExist, expectedIs: true, expectedPath: "first"},
+		{name: "later inner error", err: NewRichError("OuterCode", "outer message").AddError(io.EOF).AddError(secondPathErr), target: os.ErrPermission, expectedIs: true, expectedPath: "second"},
+		{name: "wrapped later inner error", err: NewRichError("OuterCode", "outer message").AddError(io.EOF).AddError(fmt.Errorf("reading: %w", secondPathErr)), target: os.ErrPermission, expectedIs: true, expectedPath: "second"},
+		{name: "cause", err: NewRichError("OuterCode", "outer message").AddError(io.EOF).WithCause(secondPathErr), target: os.ErrPermission, expectedIs: true, expectedPath: "second"},
+		{name: "first inner error with cause", err: NewRichError("OuterCode", "outer message").AddError(firstPathErr).WithCause(io.EOF), target: os.ErrNotExist, expectedIs: true, expectedPath: "first"},
+		{name: "nested rich error", err: NewRichError("OuterCode", "outer message").AddError(io.EOF).AddError(NewRichError("InnerCode", "inner message").AddError(io.EOF).AddError(secondPathErr)), target: os.ErrPermission, expectedIs: true, expectedPath: "second"},
+		{name: "no match", err: NewRichError("OuterCode", "outer message").AddError(io.EOF).AddError(io.ErrClosedPipe), target: os.ErrNotExist, expectedIs: false},
+	}
+	for _, tc := range testCases {
+		if actual := goerrors.Is(tc.err, tc.target); actual != tc.expectedIs {
+			t.Errorf("%s test failed: errors.Is not expected (expected: %t) (actual: %t)", tc.name, tc.expectedIs, actual)
+		}
+		var pathErr *os.PathError
+		found := goerrors.As(tc.err, &pathErr)
+		actualPath := ""
+		if found {
+			actualPath = pathErr.Path
+		}
+		if actualPath != tc.expectedPath {
+			t.Errorf("%s test failed: errors.As path error not expected (expected: %s) (actual: %s)", tc.name, tc.expectedPath, actualPath)
+		}
+	}
+}
+
+func TestErrorsAsNestedChain(t *testing.T) {
+	// every level has a second inner error so As is called at every level of the chain.
+	var err error = &os.PathError{Op: "open", Path: "deepest", Err: os.ErrNotExist}
+	for i := 0; i < 40; i++ {
+		err = NewRichError(fmt.Sprintf("LevelCode%d", i), "level message").AddError(err).AddError(io.EOF)
+	}
+	var pathErr *os.PathError
+	if !goerrors.As(err, &pathErr) || pathErr.Path != "deepest" {
+		t.Errorf("nested chain test failed: errors.As path error not expected (expected: %s) (actual: %v)", "deepest", pathErr)
+	}
+}
+
+func TestWithCause(t *testing.T) {
+	cause := NewRichError("CauseCode", "cause message")
+	err := NewRichError("OuterCode", "outer message").
+		AddError(io.ErrUnexpectedEOF).
+		WithCause(cause)
+	if err.GetCause() == nil || err.GetCause().Error() != cause.Error() {
+		t.Errorf("cause test failed: GetCause not expected (expected: %s) (actual: %v)", cause.Error(), err.GetCause())
+	}
+	if len(err.GetErrors()) != 1 {
+		t.Errorf("cause test failed: the cause should not be added to the inner errors (actual: %v)", err.GetErrors())
+	}
+	if unwrapped := goerrors.Unwrap(err); unwrapped == nil || unwrapped.(ReadOnlyRichError).GetErrorCode() != "CauseCode" {
+		t.Errorf("cause test failed: Unwrap should return the cause first (actual: %v)", unwrapped)
+	}
+	if !goerrors.Is(err, NewRichError("CauseCode", "")) || !goerrors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("cause test failed: errors.Is should match the cause and the inner errors")
+	}
+	if output := err.ToString(FullOutputFormatted); !strings.Contains(output, "CAUSE: TIMESTAMP: ") {
+		t.Errorf("cause test failed: full output missing cause section: %s", output)
+	}
+	walked := make([]string, 0)
+	err.Walk(func(err ReadOnlyRichError, depth int) bool {
+		walked = append(walked, err.GetErrorCode())
+		return true
+	})
+	if fmt.Sprint(walked) != "[OuterCode CauseCode]" {
+		t.Errorf("cause test failed: walk order not expected (expected: [OuterCode CauseCode]) (actual: %v)", walked)
+	}
+	jsonData, jsonErr := json.Marshal(err)
+	if jsonErr != nil {
+		t.Fatalf("cause test failed: failed to marshal error: %s", jsonErr.Error())
+	}
+	var unmarshaled richError
+	if jsonErr = json.Unmarshal(jsonData, &unmarshaled); jsonErr != nil {
+		t.Fatalf("cause test failed: failed to unmarshal error: %s", jsonErr.Error())
+	}
+	if unmarshaledCause, ok := unmarshaled.GetCause().(ReadOnlyRichError); !ok || unmarshaledCause.GetErrorCode() != "CauseCode" {
+		t.Errorf("cause test failed: cause not restored from JSON (actual: %v)", unmarshaled.GetCause())
+	}
+}
+
+type testContextKey string
+
+func TestFromContext(t *testing.T) {
+	defer func() {
+		contextKeys = nil
+	}()
+	RegisterContextKey(testContextKey("requestId"), "request")
+	RegisterContextKey(testContextKey("userId"), "userId")
+	RegisterContextKey(testContextKey("traceId"), "traceId")
+	RegisterContextKey(testContextKey("requestId"), "requestId")
+	ctx := context.WithValue(context.Background(), testContextKey("requestId"), "req-1")
+	ctx = context.WithValue(ctx, testContextKey("userId"), 42)
+	err := FromContext(ctx, "TestCode", "test message")
+	expectedMetaData := "map[requestId:req-1 userId:42]"
+	if fmt.Sprint(err.GetMetaData()) != expectedMetaData {
+		t.Errorf("from context test failed: metadata not expected (expected: %s) (actual: %v)", expectedMetaData, err.GetMetaData())
+	}
+	if err.GetErrorCode() != "TestCode" || err.GetErrorMessage() != "test message" {
+		t.Errorf("from context test failed: code and message not expected (actual: %s %s)", err.GetErrorCode(), err.GetErrorMessage())
+	}
+	if metaData := FromContext(context.Background(), "TestCode", "test message").GetMetaData(); len(metaData) != 0 {
+		t.Errorf("from context test failed: metadata should be empty without context values (actual: %v)", metaData)
+	}
+}
+
+func TestOutputGolden(t *testing.T) {
+	type goldenTestCase struct {
+		name   string
+		format RichErrorOutputFormat
+		file   string
+	}
+	frozenTime := time.Date(2021, time.March, 14, 15, 9, 26, 0, time.UTC)
+	SetClock(func() time.Time { return frozenTime })
+	defer SetClock(nil)
+	innerErr := NewRichError("InnerCode", "inner message").AddError(NewRichError("NestedCode", "nested message"))
+	err := NewRichError("TestCode", "test message").
+		AddError(innerErr).
+		AddMetaData("id", 42).
+		AddMetaData("name", "test")
+	testCases := []goldenTestCase{
+		{name: "detailed output", format: DetailedOutput, file: "testdata/detailed_output.golden"},
+		{name: "full output formatted", format: FullOutputFormatted, file: "testdata/full_output_formatted.golden"},
+		{name: "full output inline", format: FullOutputInline, file: "testdata/full_output_inline.golden"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expected, readErr := os.ReadFile(tc.file)
+			if readErr != nil {
+				t.Fatalf("%s test failed: unable to read golden file: %s", tc.name, readErr)
+			}
+			if actual := err.ToString(tc.format); actual != string(expected) {
+				t.Errorf("%s test failed: output does not match %s (expected: %q) (actual: %q)", tc.name, tc.file, expected, actual)
+			}
+		})
+	}
+}
+
+func TestInnerErrorIndentation(t *testing.T) {
+	type testCase struct {
+		name   string
+		format RichErrorOutputFormat
+	}
+	deepestErr := NewRichError("DeepestCode", "deepest message")
+	nestedErr := NewRichError("NestedCode", "nested message").AddError(deepestErr)
+	err := NewRichError("OuterCode", "outer message").
+		AddError(NewRichError("FirstCode", "first message")).
+		AddError(NewRichError("SecondCode", "second message")).
+		AddError(nestedErr)
+	testCases := []testCase{
+		{name: "full formatted", format: FullOutputFormatted},
+		{name: "full recursive", format: FullOutputRecursive},
+	}
+	for _, tc := range testCases {
+		output := err.ToString(tc.format)
+		for _, expected := range []string{"\n\tERROR #1: ", "\n\tERROR #2: ", "\n\tERROR #3: ", "\n\t\tERRCODE: NestedCode", "\n\t\t\tERROR #1: ", "\n\t\t\t\tERRCODE: DeepestCode"} {
+			if !strings.Contains(output, expected) {
+				t.Errorf("%s test failed: output missing expected section (expected: %q) (actual: %s)", tc.name, expected, output)
+			}
+		}
+		for _, unexpected := range []string{"\n\t\tERROR #2: ", "\n\t\t\tERROR #3: ", "\nERRCODE: DeepestCode"} {
+			if strings.Contains(output, unexpected) {
+				t.Errorf("%s test failed: inner errors should be indented by their depth (unexpected: %q) (actual: %s)", tc.name, unexpected, output)
+			}
+		}
+	}
+}
+
+func TestRegisterOutputFormat(t *testing.T) {
+	type namedFormatTestCase struct {
+		name           string
+		formatName     string
+		expectedOutput string
+	}
+	RegisterOutputFormat("slack", func(e ReadOnlyRichError) string {
+		return fmt.Sprintf(":rotating_light: *%s* %s", e.GetErrorCode(), e.GetErrorMessage())
+	})
+	RegisterOutputFormat("audit", func(e ReadOnlyRichError) string {
+		return fmt.Sprintf("audit code=%s", e.GetErrorCode())
+	})
+	defer RegisterOutputFormat("slack", nil)
+	defer RegisterOutputFormat("audit", nil)
+	err := NewRichError("TestCode", "test message")
+	testCases := []namedFormatTestCase{
+		{name: "slack format", formatName: "slack", expectedOutput: ":rotating_light: *TestCode* test message"},
+		{name: "audit format", formatName: "audit", expectedOutput: "audit code=TestCode"},
+		{name: "unknown format", formatName: "pagerduty", expectedOutput: err.ToString(FullOutputFormatted)},
+	}
+	for _, tc := range testCases {
+		if actual := err.ToNamedString(tc.formatName); actual != tc.expectedOutput {
+			t.Errorf("%s test failed: output not expected (expected: %s) (actual: %s)", tc.name, tc.expectedOutput, actual)
+		}
+	}
+	RegisterOutputFormat("audit", nil)
+	if actual := err.ToNamedString("audit"); actual != err.ToString(FullOutputFormatted) {
+		t.Errorf("unregistered format test failed: output not expected (expected: %s) (actual: %s)", err.ToString(FullOutputFormatted), actual)
+	}
+}
+
+func TestConcurrentOutputSettings(t *testing.T) {
+	defer SetErrorOutputFormat(FullOutputFormatted)
+	defer SetCustomOutputFunction(nil)
+	defer RegisterOutputFormat("concurrent", nil)
+	defer SetIncludeStackPointers(false)
+	defer SetGlobalIncludeAllTags(false)
+	defer SetGlobalAlwaysEmitStackSection(false)
+	defer SetUseShortPaths(false)
+	defer SetCaptureGoroutineID(false)
+	defer SetMaxStackDepth(0)
+	defer SetStackFramePrefilter(nil)
+	defer SetClock(nil)
+	defer SetGlobalRedactedKeys()
+	defer SetGlobalMaxBreadcrumbs(0)
+	defer SetGlobalMetricLabelKeys()
+	defer SetGlobalMaxRenderDepth(0)
+	defer SetGlobalColorEnabled(false)
+	err := NewRichError("TestCode", "test message").WithStack(0).AddMetaData("password", "hunter2")
+	ctx := context.WithValue(context.Background(), testContextKey("concurrentId"), "1")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if (i+j)%2 == 0 {
+					SetErrorOutputFormat(ShortOutput)
+				} else {
+					SetErrorOutputFormat(CustomOutput)
+				}
+				SetCustomOutputFunction(func(e ReadOnlyRichError) string { return e.GetErrorCode() })
+				RegisterOutputFormat("concurrent", func(e ReadOnlyRichError) string { return e.GetErrorMessage() })
+				SetIncludeStackPointers(j%2 == 0)
+				SetGlobalIncludeAllTags(j%2 == 0)
+				SetGlobalAlwaysEmitStackSection(j%2 == 0)
+				SetUseShortPaths(j%2 == 0)
+				SetCaptureGoroutineID(j%2 == 0)
+				SetMaxStackDepth(j % 3)
+				SetStackFramePrefilter(ExcludeFramesContaining("/runtime/"))
+				SetClock(func() time.Time { return time.Unix(0, 0).UTC() })
+				SetGlobalRedactedKeys("password")
+				SetGlobalMaxBreadcrumbs(j % 3)
+				SetGlobalMetricLabelKeys("password")
+				SetGlobalMaxRenderDepth(j%3 + 1)
+				SetGlobalColorEnabled(j%2 == 0)
+				RegisterContextKey(testContextKey("concurrentId"), "concurrentId")
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = err.Error()
+				_ = err.ToNamedString("concurrent")
+				_ = err.ToString(JSONOutput)
+				_ = err.ToMap()
+				_ = NewRichError("TestCode", "test message").ToString(FullOutputFormatted)
+				_ = err.WithStack(0)
+				_ = err.AddBreadcrumb("concurrent", nil)
+				_ = err.ToMetricLine()
+				_ = err.ToString(ColorOutput)
+				_ = FromContext(ctx, "TestCode", "test message")
+			}
+		}()
+	}
+	wg.Wait()
+	SetErrorOutputFormat(ShortOutput)
+	if expected, actual := err.ToString(ShortOutput), err.Error(); actual != expected {
+		t.Errorf("concurrent output settings test failed: output not expected (expected: %s) (actual: %s)", expected, actual)
+	}
+}
+
+func TestSetTimestampFormat(t *testing.T) {
+	type timestampFormatTestCase struct {
+		name           string
+		globalLayout   string
+		errorLayout    string
+		expectedOutput string
+	}
+	frozenTime := time.Date(2021, time.March, 14, 15, 9, 26, 500, time.UTC)
+	SetClock(func() time.Time { return frozenTime })
+	defer SetClock(nil)
+	defer SetGlobalTimestampFormat("")
+	testCases := []timestampFormatTestCase{
+		{name: "default format", expectedOutput: "2021-03-14T15:09:26.0000005Z - TestCode - test message"},
+		{name: "global format", globalLayout: time.RFC3339, expectedOutput: "2021-03-14T15:09:26Z - TestCode - test message"},
+		{name: "error format", globalLayout: time.RFC3339, errorLayout: "2006/01/02 15:04", expectedOutput: "2021/03/14 15:09 - TestCode - test message"},
+	}
+	for _, tc := range testCases {
+		SetGlobalTimestampFormat(tc.globalLayout)
+		err := NewRichError("TestCode", "test message").SetTimestampFormat(tc.errorLayout)
+		if actual := err.ToString(ShortOutput); actual != tc.expectedOutput {
+			t.Errorf("%s test failed: output not expected (expected: %s) (actual: %s)", tc.name, tc.expectedOutput, actual)
+		}
+	}
+	SetGlobalTimestampFormat(time.Kitchen)
+	err := NewRichError("TestCode", "test message").AddBreadcrumb("step", nil)
+	if expected := "#1: 3:09PM - step"; !strings.Contains(err.ToString(FullOutputFormatted), expected) {
+		t.Errorf("breadcrumb timestamp format test failed: output missing breadcrumb (expected: %s) (actual: %s)", expected, err.ToString(FullOutputFormatted))
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	type retryableTestCase struct {
+		name     string
+		err      error
+		expected bool
+	}
+	retryableErr := NewRichError("TimeoutCode", "timed out").WithRetryable(true)
+	testCases := []retryableTestCase{
+		{name: "retryable error", err: retryableErr, expected: true},
+		{name: "not retryable error", err: NewRichError("TestCode", "test message"), expected: false},
+		{name: "wrapped retryable error", err: fmt.Errorf("calling service: %w", retryableErr), expected: true},
+		{name: "retryable inner error", err: NewRichError("TestCode", "test message").AddError(goerrors.New("plain error")).AddError(retryableErr), expected: true},
+		{name: "retryable cause", err: NewRichError("TestCode", "test message").WithCause(retryableErr), expected: true},
+		{name: "plain error", err: goerrors.New("plain error"), expected: false},
+		{name: "nil error", err: nil, expected: false},
+	}
+	for _, tc := range testCases {
+		if actual := IsRetryable(tc.err); actual != tc.expected {
+			t.Errorf("%s test failed: retryable not expected (expected: %t) (actual: %t)", tc.name, tc.expected, actual)
+		}
+	}
+	if !retryableErr.IsRetryable() || retryableErr.WithRetryable(false).IsRetryable() {
+		t.Errorf("retryable method test failed: IsRetryable should report the value set by WithRetryable")
+	}
+	jsonData, err := json.Marshal(retryableErr)
+	if err != nil {
+		t.Fatalf("retryable json test failed: %s", err)
+	}
+	if !strings.Contains(string(jsonData), `"retryable":true`) {
+		t.Errorf("retryable json test failed: retryable missing (actual: %s)", jsonData)
+	}
+	reloadedErr, err := UnmarshalRichError(jsonData)
+	if err != nil || !reloadedErr.IsRetryable() {
+		t.Errorf("retryable json test failed: reloaded error should be retryable (error: %v)", err)
+	}
+	var logBuffer bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logBuffer, nil)).Error("request failed", slog.Any("err", retryableErr))
+	if !strings.Contains(logBuffer.String(), `"retryable":true`) {
+		t.Errorf("retryable log test failed: retryable missing (actual: %s)", logBuffer.String())
+	}
+}
+
+func TestWithCategory(t *testing.T) {
+	err := NewRichError("InvalidEmail", "email is invalid").WithCategory("validation")
+	if category, ok := err.GetCategory(); !ok || category != "validation" {
+		t.Errorf("category test failed: category not expected (expected: %s) (actual: %s)", "validation", category)
+	}
+	if _, ok := NewRichError("TestCode", "test message").GetCategory(); ok {
+		t.Errorf("category test failed: error without a category should report false")
+	}
+	for _, format := range []RichErrorOutputFormat{DetailedOutput, FullOutputFormatted} {
+		if output := err.ToString(format); !strings.Contains(output, "\nCATEGORY: validation\n") {
+			t.Errorf("category output test failed: category section missing (format: %s) (actual: %s)", format.String(), output)
+		}
+	}
+	jsonData, jsonErr := json.Marshal(err)
+	if jsonErr != nil {
+		t.Fatalf("category json test failed: %s", jsonErr)
+	}
+	if !strings.Contains(string(jsonData), `"category":"validation"`) {
+		t.Errorf("category json test failed: category missing (actual: %s)", jsonData)
+	}
+	var logBuffer bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logBuffer, nil)).Error("request failed", slog.Any("err", err))
+	if !strings.Contains(logBuffer.String(), `"category":"validation"`) {
+		t.Errorf("category log test failed: category missing (actual: %s)", logBuffer.String())
+	}
+}
+
+func TestGetInnerRichErrors(t *testing.T) {
+	type innerRichErrorsTestCase struct {
+		name          string
+		err           RichError
+		expectedCodes []string
+	}
+	testCases := []innerRichErrorsTestCase{
+		{name: "no inner errors", err: NewRichError("OuterCode", "outer message"), expectedCodes: nil},
+		{name: "only plain inner errors", err: NewRichError("OuterCode", "outer message").AddError(goerrors.New("plain error")), expectedCodes: nil},
+		{
+			name: "mixed inner errors",
+			err: NewRichError("OuterCode", "outer message").
+				AddError(NewRichError("FirstCode", "first message")).
+				AddError(goerrors.New("plain error")).
+				AddError(NewRichError("SecondCode", "second message")),
+			expectedCodes: []string{"FirstCode", "SecondCode"},
+		},
+	}
+	for _, tc := range testCases {
+		var actualCodes []string
+		for _, innerErr := range tc.err.GetInnerRichErrors() {
+			actualCodes = append(actualCodes, innerErr.GetErrorCode())
+		}
+		if fmt.Sprint(actualCodes) != fmt.Sprint(tc.expectedCodes) {
+			t.Errorf("%s test failed: inner error codes not expected (expected: %v) (actual: %v)", tc.name, tc.expectedCodes, actualCodes)
+		}
+	}
+}
+
+func TestAddErrorToItself(t *testing.T) {
+	type addErrorTestCase struct {
+		name          string
+		err           RichError
+		addedErr      error
+		expectedCount int
+	}
+	err := NewRichError("OuterCode", "outer message").AddError(NewRichError("InnerCode", "inner message"))
+	wrapperErr := NewRichError("WrapperCode", "wrapper message").AddError(goerrors.New("plain error")).AddError(err)
+	noInnerErr := NewRichError("TestCode", "test message")
+	baseErr := NewRichError("BaseCode", "base message").
+		AddError(goerrors.New("first inner error")).
+		AddError(goerrors.New("second inner error")).
+		AddError(goerrors.New("third inner error"))
+	testCases := []addErrorTestCase{
+		{name: "error added to itself", err: err, addedErr: err, expectedCount: 1},
+		{name: "error containing the error", err: err, addedErr: wrapperErr, expectedCount: 1},
+		{name: "error as cause", err: err, addedErr: NewRichError("CauseCode", "cause message").WithCause(err), expectedCount: 1},
+		{name: "other rich error", err: err, addedErr: NewRichError("OtherCode", "other message"), expectedCount: 2},
+		{name: "plain error", err: err, addedErr: goerrors.New("plain error"), expectedCount: 2},
+		{name: "error without inner errors added to itself", err: noInnerErr, addedErr: noInnerErr, expectedCount: 0},
+		{name: "identical error without inner errors", err: noInnerErr, addedErr: NewRichError("TestCode", "test message"), expectedCount: 1},
+		{name: "copy sharing inner errors", err: baseErr.AddTag("a"), addedErr: baseErr.AddTag("b"), expectedCount: 4},
+		{name: "error it was built from", err: baseErr.AddTag("a"), addedErr: baseErr, expectedCount: 4},
+	}
+	for _, tc := range testCases {
+		actual := tc.err.AddError(tc.addedErr)
+		if len(actual.GetErrors()) != tc.expectedCount {
+			t.Errorf("%s test failed: inner error count not expected (expected: %d) (actual: %d)", tc.name, tc.expectedCount, len(actual.GetErrors()))
+		}
+		if output := actual.ToString(FullOutputRecursive); strings.Contains(output, cycleDetectedMessage) {
+			t.Errorf("%s test failed: output should not contain a cycle (actual: %s)", tc.name, output)
+		}
+	}
+}
+
+func TestAddNilError(t *testing.T) {
+	type nilErrorTestCase struct {
+		name          string
+		err           RichError
+		expectedCount int
+	}
+	modifiedErr := NewRichError("TestCode", "test message").AddError(goerrors.New("plain error"))
+	modifiedErr.GetErrors()[0] = nil
+	testCases := []nilErrorTestCase{
+		{name: "add nil error", err: NewRichError("TestCode", "test message").AddError(nil), expectedCount: 0},
+		{name: "with nil errors", err: NewRichError("TestCode", "test message").WithErrors([]error{nil, goerrors.New("plain error"), nil}), expectedCount: 1},
+		{name: "nil error stored in inner errors", err: modifiedErr, expectedCount: 1},
+	}
+	for _, tc := range testCases {
+		if len(tc.err.GetErrors()) != tc.expectedCount {
+			t.Errorf("%s test failed: inner error count not expected (expected: %d) (actual: %d)", tc.name, tc.expectedCount, len(tc.err.GetErrors()))
+		}
+		for _, format := range []RichErrorOutputFormat{FullOutputFormatted, FullOutputInline, FullOutputRecursive, JSONOutput} {
+			// rendering must not panic on a nil inner error.
+			tc.err.ToString(format)
+		}
+	}
+	if output := modifiedErr.ToString(FullOutputFormatted); !strings.Contains(output, "<nil>") {
+		t.Errorf("nil error test failed: nil inner error not rendered (expected: %s) (actual: %s)", "<nil>", output)
+	}
+}
+
+func TestGetStackStrings(t *testing.T) {
+	if stackStrings := NewRichError("TestCode", "test message").GetStackStrings(); stackStrings != nil {
+		t.Errorf("stack strings test failed: error without a stack should have no stack strings (actual: %v)", stackStrings)
+	}
+	err := NewRichError("TestCode", "test message").WithStack(0)
+	stack := err.GetStack()
+	stackStrings := err.GetStackStrings()
+	if len(stackStrings) != len(stack) {
+		t.Fatalf("stack strings test failed: stack string count not expected (expected: %d) (actual: %d)", len(stack), len(stackStrings))
+	}
+	for i, frame := range stack {
+		if expected := frame.String(); stackStrings[i] != expected {
+			t.Errorf("stack strings test failed: stack string %d not expected (expected: %s) (actual: %s)", i, expected, stackStrings[i])
+		}
+	}
+	if !strings.Contains(stackStrings[0], "TestGetStackStrings") {
+		t.Errorf("stack strings test failed: first frame should be the test function (actual: %s)", stackStrings[0])
+	}
+}
+
+func TestSetStackFrameFormatter(t *testing.T) {
+	SetStackFrameFormatter(func(frame CallStackEntry) string {
+		return fmt.Sprintf("%s:%d %s", frame.File, frame.Line, frame.Function)
+	})
+	defer SetStackFrameFormatter(nil)
+	err := NewRichError("TestCode", "test message").WithStack(0)
+	firstFrame := err.GetStack()[0]
+	expectedFrame := fmt.Sprintf("%s:%d %s", firstFrame.File, firstFrame.Line, firstFrame.Function)
+	if output := err.ToString(FullOutputFormatted); !strings.Contains(output, "STACK: "+expectedFrame+"\n") {
+		t.Errorf("stack frame formatter test failed: output missing formatted frame (expected: %s) (actual: %s)", expectedFrame, output)
+	}
+	if stackStrings := err.GetStackStrings(); stackStrings[0] != expectedFrame {
+		t.Errorf("stack frame formatter test failed: stack string not expected (expected: %s) (actual: %s)", expectedFrame, stackStrings[0])
+	}
+	SetStackFrameFormatter(nil)
+	if output := err.ToString(FullOutputFormatted); !strings.Contains(output, "STACK: "+firstFrame.String()+"\n") {
+		t.Errorf("stack frame formatter test failed: output should use the default format after the formatter is removed (expected: %s) (actual: %s)", firstFrame.String(), output)
+	}
+}
+
+func TestJSONInlineOutput(t *testing.T) {
+	err := NewRichError("OuterCode", "outer message").
+		WithStack(0).
+		AddError(NewRichError("InnerCode", "inner message"))
+	prettyOutput := err.ToString(JSONOutput)
+	inlineOutput := err.ToString(JSONInlineOutput)
+	if strings.ContainsAny(inlineOutput, "\n\t") {
+		t.Errorf("json inline output test failed: output should be a single line: %s", inlineOutput)
+	}
+	if !strings.Contains(prettyOutput, "\n  \"code\": \"OuterCode\"") {
+		t.Errorf("json output test failed: output should be indented: %s", prettyOutput)
+	}
+	var compactedOutput bytes.Buffer
+	if compactErr := json.Compact(&compactedOutput, []byte(prettyOutput)); compactErr != nil {
+		t.Fatalf("json output test failed: output is not valid JSON: %s", compactErr)
+	}
+	if compactedOutput.String() != inlineOutput {
+		t.Errorf("json inline output test failed: output should be the compacted json output (expected: %s) (actual: %s)", compactedOutput.String(), inlineOutput)
+	}
+	for _, expected := range []string{`"innerErrors":[{"code":"InnerCode"`, `"stack":[{"depth":0`} {
+		if !strings.Contains(inlineOutput, expected) {
+			t.Errorf("json inline output test failed: output missing expected section (expected: %s) (actual: %s)", expected, inlineOutput)
+		}
+	}
+}
+
+func TestShortNoTimeOutput(t *testing.T) {
+	err := NewRichError("NotFound", "user not found").AddMetaData("userId", 42)
+	expectedOutput := "NotFound - user not found"
+	if actual := err.ToString(ShortNoTimeOutput); actual != expectedOutput {
+		t.Errorf("short no time output test failed: output not expected (expected: %s) (actual: %s)", expectedOutput, actual)
+	}
+	if actual := err.SetOutputFormat(ShortNoTimeOutput).Error(); actual != expectedOutput {
+		t.Errorf("short no time output test failed: Error() output not expected (expected: %s) (actual: %s)", expectedOutput, actual)
+	}
+}
+
+func TestGetEffectiveOutputFormat(t *testing.T) {
+	type effectiveFormatTestCase struct {
+		name           string
+		globalFormat   RichErrorOutputFormat
+		errorFormat    RichErrorOutputFormat
+		expectedFormat RichErrorOutputFormat
+	}
+	defer SetErrorOutputFormat(FullOutputFormatted)
+	testCases := []effectiveFormatTestCase{
+		{name: "global format", globalFormat: DetailedOutput, errorFormat: NotSpecified, expectedFormat: DetailedOutput},
+		{name: "error format", globalFormat: DetailedOutput, errorFormat: ShortOutput, expectedFormat: ShortOutput},
+	}
+	for _, tc := range testCases {
+		SetErrorOutputFormat(tc.globalFormat)
+		err := NewRichError("TestCode", "test message").SetOutputFormat(tc.errorFormat)
+		if actual := err.GetEffectiveOutputFormat(); actual != tc.expectedFormat {
+			t.Errorf("%s test failed: effective output format not expected (expected: %s) (actual: %s)", tc.name, tc.expectedFormat.String(), actual.String())
+		}
+	}
+	SetCustomOutputFunction(nil)
+	if HasCustomOutputFunction() {
+		t.Errorf("custom output function test failed: no custom output function should be set")
+	}
+	SetCustomOutputFunction(func(e ReadOnlyRichError) string { return e.GetErrorCode() })
+	defer SetCustomOutputFunction(nil)
+	if !HasCustomOutputFunction() {
+		t.Errorf("custom output function test failed: a custom output function should be set")
+	}
+}
+
+func TestRichErrorOutputFormatText(t *testing.T) {
+	type outputFormatTextTestCase struct {
+		name           string
+		text           string
+		expectedFormat RichErrorOutputFormat
+		expectedError  string
+	}
+	testCases := []outputFormatTextTestCase{
+		{name: "exact name", text: "FullOutputFormatted", expectedFormat: FullOutputFormatted},
+		{name: "lower case name", text: "jsoninlineoutput", expectedFormat: JSONInlineOutput},
+		{name: "unknown name", text: "FullOutputFormated", expectedError: "unknown output format: FullOutputFormated"},
+	}
+	for _, tc := range testCases {
+		var format RichErrorOutputFormat
+		err := format.UnmarshalText([]byte(tc.text))
+		if tc.expectedError != "" {
+			if err == nil || err.Error() != tc.expectedError {
+				t.Errorf("%s test failed: error not expected (expected: %s) (actual: %v)", tc.name, tc.expectedError, err)
+			}
+			continue
+		}
+		if err != nil || format != tc.expectedFormat {
+			t.Errorf("%s test failed: output format not expected (expected: %s) (actual: %s) (error: %v)", tc.name, tc.expectedFormat, format, err)
+		}
+	}
+	if actual := RichErrorOutputFormat(99).String(); actual != "RichErrorOutputFormat(99)" {
+		t.Errorf("unknown output format string test failed: (expected: %s) (actual: %s)", "RichErrorOutputFormat(99)", actual)
+	}
+	type outputConfig struct {
+		Format RichErrorOutputFormat `json:"format"`
+	}
+	configJSON, err := json.Marshal(outputConfig{Format: DetailedOutput})
+	if err != nil || string(configJSON) != `{"format":"DetailedOutput"}` {
+		t.Errorf("output format json test failed: (expected: %s) (actual: %s) (error: %v)", `{"format":"DetailedOutput"}`, configJSON, err)
+	}
+	var config outputConfig
+	if err := json.Unmarshal(configJSON, &config); err != nil || config.Format != DetailedOutput {
+		t.Errorf("output format json test failed: format not expected (expected: %s) (actual: %s) (error: %v)", DetailedOutput, config.Format, err)
+	}
+}
+
+func TestGetAllTags(t *testing.T) {
+	deepestErr := NewRichError("DeepestCode", "deepest message").WithTags([]string{"db", "timeout"})
+	innerErr := NewRichError("InnerCode", "inner message").WithTags([]string{"retryable", "db"}).AddError(deepestErr)
+	err := NewRichError("OuterCode", "outer message").
+		WithTags([]string{"api"}).
+		AddError(goerrors.New("plain error")).
+		AddError(innerErr).
+		WithCause(NewRichError("CauseCode", "cause message").WithTags([]string{"network"}))
+	expectedTags := []string{"api", "network", "retryable", "db", "timeout"}
+	if actual := err.GetAllTags(); fmt.Sprint(actual) != fmt.Sprint(expectedTags) {
+		t.Errorf("all tags test failed: tags not expected (expected: %v) (actual: %v)", expectedTags, actual)
+	}
+	if actual := err.GetTags(); fmt.Sprint(actual) != "[api]" {
+		t.Errorf("all tags test failed: GetTags should only return the tags of the error (actual: %v)", actual)
+	}
+	if strings.Contains(err.ToString(JSONInlineOutput), `"allTags"`) {
+		t.Errorf("all tags test failed: JSON output should not include all tags by default: %s", err.ToString(JSONInlineOutput))
+	}
+	SetGlobalIncludeAllTags(true)
+	defer SetGlobalIncludeAllTags(false)
+	expectedJSON := `"allTags":["api","network","retryable","db","timeout"]`
+	if output := err.ToString(JSONInlineOutput); !strings.Contains(output, expectedJSON) {
+		t.Errorf("all tags test failed: JSON output missing all tags (expected: %s) (actual: %s)", expectedJSON, output)
+	}
+	if allTags, ok := err.ToMap()["allTags"].([]string); !ok || fmt.Sprint(allTags) != fmt.Sprint(expectedTags) {
+		t.Errorf("all tags test failed: map all tags not expected (expected: %v) (actual: %v)", expectedTags, err.ToMap()["allTags"])
+	}
+	var logBuffer bytes.Buffer
+	slog.New(slog.NewJSONHandler(&logBuffer, nil)).Error("request failed", slog.Any("err", err))
+	if !strings.Contains(logBuffer.String(), expectedJSON) {
+		t.Errorf("all tags test failed: log missing all tags (expected: %s) (actual: %s)", expectedJSON, logBuffer.String())
+	}
+}
+
+func recoverPanic(code string, panicFunc func()) (err RichError) {
+	defer func() {
+		err = RecoverToRichError(code, recover())
+	}()
+	panicFunc()
+	return nil
+}
+
+func panickingFunction(value interface{}) {
+	panic(value)
+}
+
+func nilPointerFunction() int {
+	var values *[]int
+	return len(*values)
+}
+
+func TestRecoverToRichError(t *testing.T) {
+	type recoverTestCase struct {
+		name             string
+		panicFunc        func()
+		expectedMessage  string
+		expectedFunction string
+	}
+	testCases := []recoverTestCase{
+		{name: "string panic", panicFunc: func() { panickingFunction("boom") }, expectedMessage: "panic: boom", expectedFunction: "panickingFunction"},
+		{name: "error panic", panicFunc: func() { panickingFunction(io.EOF) }, expectedMessage: "panic: EOF", expectedFunction: "panickingFunction"},
+		{name: "runtime panic", panicFunc: func() { nilPointerFunction() }, expectedMessage: "panic: runtime error: invalid memory address or nil pointer dereference", expectedFunction: "nilPointerFunction"},
+	}
+	for _, tc := range testCases {
+		err := recoverPanic("PanicCode", tc.panicFunc)
+		if err == nil {
+			t.Fatalf("%s test failed: recovered panic should produce an error", tc.name)
+		}
+		if err.GetErrorCode() != "PanicCode" || err.GetErrorMessage() != tc.expectedMessage {
+			t.Errorf("%s test failed: error not expected (expected: PanicCode - %s) (actual: %s - %s)", tc.name, tc.expectedMessage, err.GetErrorCode(), err.GetErrorMessage())
+		}
+		if _, ok := err.GetMetaData()[PanicValueMetaDataKey]; !ok {
+			t.Errorf("%s test failed: panic value missing from metadata (actual: %v)", tc.name, err.GetMetaData())
+		}
+		if err.GetFunction() != tc.expectedFunction {
+			t.Errorf("%s test failed: stack should start at the panicking function (expected: %s) (actual: %s)", tc.name, tc.expectedFunction, err.GetFunction())
+		}
+	}
+	if err := recoverPanic("PanicCode", func() { panickingFunction(io.EOF) }); !goerrors.Is(err, io.EOF) {
+		t.Errorf("recover error panic test failed: recovered error should match the panic value with errors.Is")
+	}
+	if err := recoverPanic("PanicCode", func() {}); err != nil {
+		t.Errorf("recover without panic test failed: no error expected (actual: %s)", err)
+	}
+}
+
+func wrapResult(err error) error {
+	return Wrap(err, "WrappedCode", "wrapped message")
+}
+
+func TestWrap(t *testing.T) {
+	if err := wrapResult(nil); err != nil {
+		t.Errorf("wrap nil test failed: wrapping nil should return a nil error (actual: %v)", err)
+	}
+	err := Wrap(io.EOF, "WrappedCode", "wrapped message")
+	if err.GetErrorCode() != "WrappedCode" || err.GetErrorMessage() != "wrapped message" {
+		t.Errorf("wrap test failed: error not expected (expected: WrappedCode - wrapped message) (actual: %s - %s)", err.GetErrorCode(), err.GetErrorMessage())
+	}
+	if innerErrs := err.GetErrors(); len(innerErrs) != 1 || innerErrs[0] != io.EOF {
+		t.Errorf("wrap test failed: inner errors not expected (expected: [%v]) (actual: %v)", io.EOF, innerErrs)
+	}
+	if !goerrors.Is(err, io.EOF) {
+		t.Errorf("wrap test failed: wrapped error should match the inner error with errors.Is")
+	}
+	if err.GetFunction() != "TestWrap" {
+		t.Errorf("wrap test failed: stack should start at the caller of Wrap (expected: TestWrap) (actual: %s)", err.GetFunction())
+	}
+}
+
+func TestSetIncludeStackPointers(t *testing.T) {
+	err := NewRichError("TestCode", "test message").WithStack(0)
+	frame := err.GetStack()[0]
+	jsonData, marshalErr := json.Marshal(frame)
+	if marshalErr != nil {
+		t.Fatalf("stack pointers test failed: %s", marshalErr)
+	}
+	expectedJSON := fmt.Sprintf(`{"depth":0,"file":%q,"function":%q,"line":%d}`, frame.File, frame.Function, frame.Line)
+	if string(jsonData) != expectedJSON {
+		t.Errorf("stack pointers test failed: json not expected (expected: %s) (actual: %s)", expectedJSON, jsonData)
+	}
+	if _, ok := err.ToMap()["stack"].([]map[string]interface{})[0]["pc"]; ok {
+		t.Errorf("stack pointers test failed: map should not include program counters by default")
+	}
+	SetIncludeStackPointers(true)
+	defer SetIncludeStackPointers(false)
+	reloadedErr, unmarshalErr := UnmarshalRichError([]byte(err.ToString(JSONInlineOutput)))
+	if unmarshalErr != nil {
+		t.Fatalf("stack pointers test failed: %s", unmarshalErr)
+	}
+	if reloadedFrame := reloadedErr.GetStack()[0]; reloadedFrame != frame {
+		t.Errorf("stack pointers test failed: reloaded frame not expected (expected: %+v) (actual: %+v)", frame, reloadedFrame)
+	}
+	if !reloadedErr.HasValidPCs() {
+		t.Errorf("stack pointers test failed: reloaded error should have valid program counters")
+	}
+	if pc, ok := err.ToMap()["stack"].([]map[string]interface{})[0]["pc"]; !ok || pc != frame.PC {
+		t.Errorf("stack pointers test failed: map program counter not expected (expected: %d) (actual: %v)", frame.PC, pc)
+	}
+}
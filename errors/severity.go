@@ -0,0 +1,120 @@
+package errors
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Severity describes how serious an error is so log routers can decide how to handle it.
+type Severity int
+
+const (
+	// SeverityNotSpecified is the zero value. Errors without a severity report SeverityError.
+	SeverityNotSpecified Severity = iota
+	SeverityDebug
+	SeverityInfo
+	SeverityWarn
+	SeverityError
+	SeverityFatal
+)
+
+var severityNames = map[Severity]string{
+	SeverityNotSpecified: "notSpecified",
+	SeverityDebug:        "debug",
+	SeverityInfo:         "info",
+	SeverityWarn:         "warn",
+	SeverityError:        "error",
+	SeverityFatal:        "fatal",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	name := strings.ToLower(string(text))
+	for severity, severityName := range severityNames {
+		if strings.ToLower(severityName) == name {
+			*s = severity
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity: %s", text)
+}
+
+var (
+	categoryPrecedenceMutex sync.RWMutex
+	categoryPrecedence      map[string]int
+)
+
+// SetCategoryPrecedence sets the order MoreSevereThan uses to compare the categories of errors with the same severity.
+// Categories listed earlier are more severe, e.g. SetCategoryPrecedence("auth", "io", "validation"). Categories that
+// are not listed, including no category, rank below every listed category and equal to each other. Calling it with no
+// categories removes the precedence so categories never decide the comparison.
+func SetCategoryPrecedence(categories ...string) {
+	precedence := make(map[string]int, len(categories))
+	for i, category := range categories {
+		if _, ok := precedence[category]; !ok {
+			precedence[category] = len(categories) - i
+		}
+	}
+	categoryPrecedenceMutex.Lock()
+	defer categoryPrecedenceMutex.Unlock()
+	categoryPrecedence = precedence
+}
+
+// severityRank returns the rank of a severity for comparisons. Unknown severities rank like SeverityError,
+// which is the severity of errors without one.
+func severityRank(severity Severity) int {
+	if _, ok := severityNames[severity]; !ok || severity == SeverityNotSpecified {
+		return int(SeverityError)
+	}
+	return int(severity)
+}
+
+// categoryRank returns the rank of a category from SetCategoryPrecedence, or zero when it is not listed.
+func categoryRank(category string) int {
+	categoryPrecedenceMutex.RLock()
+	defer categoryPrecedenceMutex.RUnlock()
+	return categoryPrecedence[category]
+}
+
+// httpStatusClassRank returns the class of an HTTP status, e.g. 5 for 503, or zero when there is no status.
+func httpStatusClassRank(status int, ok bool) int {
+	if !ok {
+		return 0
+	}
+	return status / 100
+}
+
+// MoreSevereThan reports whether the error should be propagated in preference to other when both operations failed.
+// Errors are compared by the following precedence, moving to the next only when the previous one is equal:
+//
+//  1. Severity, where fatal > error > warn > info > debug. Errors without a severity or with an unknown severity
+//     rank as error.
+//  2. Category, ordered by SetCategoryPrecedence. Without a precedence set categories are always equal.
+//  3. HTTP status class, where 5xx > 4xx > 3xx > 2xx > 1xx > no status.
+//
+// Errors that are equal in every dimension are not more severe than each other. Every error is more severe than nil.
+func (e richError) MoreSevereThan(other ReadOnlyRichError) bool {
+	if other == nil {
+		return true
+	}
+	if rank, otherRank := severityRank(e.GetSeverity()), severityRank(other.GetSeverity()); rank != otherRank {
+		return rank > otherRank
+	}
+	category, _ := e.GetCategory()
+	otherCategory, _ := other.GetCategory()
+	if rank, otherRank := categoryRank(category), categoryRank(otherCategory); rank != otherRank {
+		return rank > otherRank
+	}
+	return httpStatusClassRank(e.GetHTTPStatus()) > httpStatusClassRank(other.GetHTTPStatus())
+}
@@ -0,0 +1,103 @@
+package errors
+
+import (
+	"log/slog"
+	"strconv"
+)
+
+// LogValue implements slog.LogValuer so slog.Any("err", richErr) expands into grouped attributes
+// instead of the output of Error(). Metadata, the cause, inner errors and related errors are rendered as nested groups.
+func (e richError) LogValue() slog.Value {
+	return e.logValue(newRenderState())
+}
+
+// logValue returns the slog value of e, or a group with only a message explaining why it was not
+// expanded when the maximum render depth is reached or e contains itself.
+func (e richError) logValue(state *renderState) slog.Value {
+	leave, message := state.enter(e)
+	if leave == nil {
+		return slog.GroupValue(slog.String("message", message))
+	}
+	defer leave()
+	attrs := []slog.Attr{
+		slog.String("code", e.ErrCode),
+		slog.String("message", e.Message),
+		slog.String("severity", e.GetSeverity().String()),
+	}
+	if e.Source != "" {
+		attrs = append(attrs, slog.String("source", e.Source))
+	}
+	if e.Line != "" {
+		attrs = append(attrs, slog.String("line", e.Line))
+	}
+	if len(e.Tags) > 0 {
+		attrs = append(attrs, slog.Any("tags", e.Tags))
+	}
+	if getIncludeAllTags() {
+		if allTags := e.GetAllTags(); len(allTags) > 0 {
+			attrs = append(attrs, slog.Any("allTags", allTags))
+		}
+	}
+	if e.Action != "" {
+		attrs = append(attrs, slog.String("action", e.Action))
+	}
+	if e.Category != "" {
+		attrs = append(attrs, slog.String("category", e.Category))
+	}
+	if e.HTTPStatus != 0 {
+		attrs = append(attrs, slog.Int("httpStatus", e.HTTPStatus))
+	}
+	if e.GRPCCode != 0 {
+		attrs = append(attrs, slog.Uint64("grpcCode", uint64(e.GRPCCode)))
+	}
+	if e.Retryable {
+		attrs = append(attrs, slog.Bool("retryable", true))
+	}
+	if e.RetryAfter != nil {
+		attrs = append(attrs, slog.Duration("retryAfter", *e.RetryAfter))
+	}
+	if len(e.MetaData) > 0 {
+		metaData := e.redactedMetaData()
+		metaDataAttrs := make([]slog.Attr, 0, len(metaData))
+		for _, key := range e.sortedMetaDataKeys() {
+			metaDataAttrs = append(metaDataAttrs, slog.Any(key, metaData[key]))
+		}
+		attrs = append(attrs, slog.Attr{Key: "metaData", Value: slog.GroupValue(metaDataAttrs...)})
+	}
+	if e.Cause != nil {
+		attrs = append(attrs, slog.Attr{Key: "cause", Value: errorLogValue(e.inheritRedactedKeys(e.Cause), state)})
+	}
+	if len(e.InnerErrors) > 0 {
+		innerErrorAttrs := make([]slog.Attr, 0, len(e.InnerErrors))
+		for i, err := range e.InnerErrors {
+			if err == nil {
+				continue
+			}
+			innerErrorAttrs = append(innerErrorAttrs, slog.Attr{Key: strconv.Itoa(i), Value: errorLogValue(e.inheritRedactedKeys(err), state)})
+		}
+		attrs = append(attrs, slog.Attr{Key: "innerErrors", Value: slog.GroupValue(innerErrorAttrs...)})
+	}
+	if len(e.RelatedErrors) > 0 {
+		relatedErrorAttrs := make([]slog.Attr, 0, len(e.RelatedErrors))
+		for i, relatedErr := range e.RelatedErrors {
+			relatedErrorAttrs = append(relatedErrorAttrs, slog.Group(strconv.Itoa(i),
+				slog.String("relation", relatedErr.Relation),
+				slog.Attr{Key: "error", Value: errorLogValue(e.inheritRedactedKeys(relatedErr.Err), state)},
+			))
+		}
+		attrs = append(attrs, slog.Attr{Key: "relatedErrors", Value: slog.GroupValue(relatedErrorAttrs...)})
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// errorLogValue returns the slog value of a cause, inner or related error.
+func errorLogValue(err error, state *renderState) slog.Value {
+	switch innerErr := err.(type) {
+	case richError:
+		return innerErr.logValue(state)
+	case slog.LogValuer:
+		return innerErr.LogValue()
+	default:
+		return slog.GroupValue(slog.String("message", innerErr.Error()))
+	}
+}
@@ -0,0 +1,267 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// omittedFramesFunction is the function name of the entry added to the end of a stack that was cut short by a maximum depth.
+const omittedFramesFunction = "... %d frames omitted"
+
+var (
+	maxStackDepth       int
+	stackFrameFilter    StackFrameFilter
+	stackFrameFormatter StackFrameFormatter
+	// includeStackPointers controls whether the program counters of stack entries are serialized.
+	includeStackPointers bool
+)
+
+// CallStackEntry is a frame of a captured stack.
+type CallStackEntry = callStackEntry
+
+// StackFrameFormatter renders a stack frame in the text output formats, e.g. as "file:line function".
+type StackFrameFormatter func(frame CallStackEntry) string
+
+// StackFrameFilter reports whether a frame should be excluded from captured stacks.
+type StackFrameFilter func(frame runtime.Frame) bool
+
+// SetMaxStackDepth sets the maximum number of frames WithStack retains. A depth of zero or less retains every frame, which is the default.
+func SetMaxStackDepth(depth int) {
+	if depth < 0 {
+		depth = 0
+	}
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	maxStackDepth = depth
+}
+
+func getMaxStackDepth() int {
+	outputSettingsMutex.RLock()
+	defer outputSettingsMutex.RUnlock()
+	return maxStackDepth
+}
+
+// SetStackFramePrefilter sets a filter that excludes frames from stacks captured after it is set, e.g. frames from
+// the runtime or a vendored framework that obscure the frames of your own code. Excluded frames do not count towards
+// the maximum stack depth and the Depth of the remaining entries stays contiguous, so the entry after an excluded frame
+// takes its Depth. The source, function and line of the error come from the first frame that is not excluded.
+// Passing nil removes the filter.
+func SetStackFramePrefilter(filter StackFrameFilter) {
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	stackFrameFilter = filter
+}
+
+func getStackFrameFilter() StackFrameFilter {
+	outputSettingsMutex.RLock()
+	defer outputSettingsMutex.RUnlock()
+	return stackFrameFilter
+}
+
+// ExcludeFramesContaining returns a StackFrameFilter that excludes frames whose file or function contains any of the
+// patterns, e.g. ExcludeFramesContaining("/runtime/", "github.com/some/framework").
+func ExcludeFramesContaining(patterns ...string) StackFrameFilter {
+	return func(frame runtime.Frame) bool {
+		for _, pattern := range patterns {
+			if strings.Contains(frame.File, pattern) || strings.Contains(frame.Function, pattern) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// SetStackFrameFormatter sets how each stack frame is rendered in the text output formats and GetStackStrings, so
+// stacks can match existing stack trace conventions. Passing nil restores the default "L:%d %v - %s:%d - %s" layout
+// of the String method of CallStackEntry.
+func SetStackFrameFormatter(formatter StackFrameFormatter) {
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	stackFrameFormatter = formatter
+}
+
+// formatStackFrame renders frame with the stack frame formatter, or its String method when none is set.
+func formatStackFrame(frame callStackEntry) string {
+	outputSettingsMutex.RLock()
+	formatter := stackFrameFormatter
+	outputSettingsMutex.RUnlock()
+	if formatter == nil {
+		return frame.String()
+	}
+	return formatter(frame)
+}
+
+// SetIncludeStackPointers sets whether the JSON output and ToMap include the entry and pc program counters of stack
+// entries. They are omitted by default because they are only meaningful in the process that captured the stack, so
+// leaving them out keeps stored stacks portable and comparable. Enable them for local debugging or to use ReSymbolize
+// on errors reconstructed from JSON.
+func SetIncludeStackPointers(include bool) {
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	includeStackPointers = include
+}
+
+func getIncludeStackPointers() bool {
+	outputSettingsMutex.RLock()
+	defer outputSettingsMutex.RUnlock()
+	return includeStackPointers
+}
+
+// jsonCallStackEntry is the JSON representation of a callStackEntry.
+type jsonCallStackEntry struct {
+	Depth    int     `json:"depth"`
+	Entry    uintptr `json:"entry,omitempty"`
+	File     string  `json:"file"`
+	Function string  `json:"function"`
+	Line     int     `json:"line"`
+	PC       uintptr `json:"pc,omitempty"`
+}
+
+// MarshalJSON renders the stack entry without its program counters unless SetIncludeStackPointers is enabled.
+func (cse callStackEntry) MarshalJSON() ([]byte, error) {
+	entry := jsonCallStackEntry{
+		Depth:    cse.Depth,
+		File:     cse.File,
+		Function: cse.Function,
+		Line:     cse.Line,
+	}
+	if getIncludeStackPointers() {
+		entry.Entry = cse.Entry
+		entry.PC = cse.PC
+	}
+	return json.Marshal(entry)
+}
+
+// lazyStack holds the program counters captured by WithStack. They are only resolved into call stack entries the
+// first time the stack is needed, because most errors are handled without ever being formatted. The lazyStack is
+// shared by every copy of the error, so the entries are resolved at most once.
+type lazyStack struct {
+	pcs []uintptr
+	// maxDepth is the maximum number of entries to resolve, zero means no limit.
+	maxDepth int
+	// omittedPCs is the number of program counters past maxDepth that were not retained.
+	omittedPCs int
+	// filter excludes frames when the entries are resolved, it is the prefilter set when the stack was captured.
+	filter  StackFrameFilter
+	once    sync.Once
+	entries []callStackEntry
+}
+
+func (s *lazyStack) resolve() []callStackEntry {
+	s.once.Do(func() {
+		s.entries = resolveCallStack(s.pcs, s.maxDepth, s.omittedPCs, s.filter)
+	})
+	return s.entries
+}
+
+// captureStack records the program counters of the stack, skipping stackOffset frames above the caller of the
+// RichError method that called it, and retaining at most maxDepth of them when maxDepth is positive.
+func (e richError) captureStack(stackOffset, maxDepth int) RichError {
+	// Here we use 3 to remove the runtime.Callers call, this call
+	// and the call to the RichError method such as WithStack.
+	// This should leave only the relevant stack pieces
+	baseStackOffset := 3
+	// The runtime.Callers function will not grow the slice as needed,
+	// so we keep doubling it until the whole stack fits.
+	var callerData []uintptr = make([]uintptr, 32)
+	var numFrames int
+	for {
+		numFrames = runtime.Callers(baseStackOffset+stackOffset, callerData)
+		if numFrames < len(callerData) {
+			break
+		}
+		callerData = make([]uintptr, len(callerData)*2)
+	}
+	if numFrames == 0 {
+		return e
+	}
+	if maxDepth < 0 {
+		maxDepth = 0
+	}
+	filter := getStackFrameFilter()
+	pcs := callerData[:numFrames]
+	omittedPCs := 0
+	// with a filter it is not known how many program counters are needed for maxDepth frames until they are resolved.
+	if maxDepth > 0 && numFrames > maxDepth && filter == nil {
+		// copy the retained program counters so the rest of the buffer can be released.
+		pcs = append(make([]uintptr, 0, maxDepth), callerData[:maxDepth]...)
+		omittedPCs = numFrames - maxDepth
+	}
+	// Only the first frame is resolved here for the source, function and line. The rest of the
+	// stack is resolved the first time it is needed, which keeps capturing a stack cheap.
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		if filter == nil || !filter(frame) {
+			e.setLocation(frame.File, frame.Function, frame.Line)
+			break
+		}
+		if !more {
+			break
+		}
+	}
+	if captureGoroutineID.Load() {
+		e.GoroutineID = currentGoroutineID()
+	}
+	e.Stack = nil
+	e.lazyStack = &lazyStack{pcs: pcs, maxDepth: maxDepth, omittedPCs: omittedPCs, filter: filter}
+	return e.withNewID()
+}
+
+// resolveCallStack resolves program counters from runtime.Callers into at most maxDepth call stack entries, skipping
+// frames excluded by the filter. If frames are dropped because of maxDepth, including the omittedPCs program counters
+// that were never retained, a final entry records how many.
+func resolveCallStack(pcs []uintptr, maxDepth, omittedPCs int, filter StackFrameFilter) []callStackEntry {
+	if len(pcs) == 0 {
+		return nil
+	}
+	stack := make([]callStackEntry, 0, len(pcs)+1)
+	frames := runtime.CallersFrames(pcs)
+	// Inlined calls can expand into more frames than program counters,
+	// so we iterate until the frames are exhausted.
+	omittedFrames := omittedPCs
+	for {
+		frame, more := frames.Next()
+		switch {
+		case filter != nil && filter(frame):
+			// excluded frames are skipped without leaving a gap in the depth numbering.
+		case maxDepth > 0 && len(stack) >= maxDepth:
+			omittedFrames++
+		default:
+			stack = append(stack, callStackEntry{
+				Depth:    len(stack),
+				Entry:    frame.Entry,
+				File:     frame.File,
+				Function: frame.Function,
+				Line:     frame.Line,
+				PC:       frame.PC,
+			})
+		}
+		if !more {
+			break
+		}
+	}
+	if omittedFrames > 0 {
+		stack = append(stack, callStackEntry{
+			Depth:    len(stack),
+			Function: fmt.Sprintf(omittedFramesFunction, omittedFrames),
+		})
+	}
+	return stack
+}
+
+// isOmittedFrames reports whether the entry is the synthetic entry recording frames dropped by a maximum stack depth.
+func (cse *callStackEntry) isOmittedFrames() bool {
+	return cse.PC == 0 && strings.HasPrefix(cse.Function, "... ") && strings.HasSuffix(cse.Function, " frames omitted")
+}
+
+// stack returns the call stack of the error, resolving the captured program counters if they have not been resolved yet.
+func (e richError) stack() []callStackEntry {
+	if e.lazyStack != nil {
+		return e.lazyStack.resolve()
+	}
+	return e.Stack
+}
@@ -0,0 +1,37 @@
+package errors
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// TemplateOutputFunc compiles tmpl with text/template and returns a CustomOutputFunc that executes it.
+// The template is executed with the ReadOnlyRichError, so its accessors are available, e.g. {{.GetErrorCode}}: {{.GetErrorMessage}}.
+// The returned function can be passed to ToCustomString to render a single error with the template.
+func TemplateOutputFunc(tmpl string) (CustomOutputFunc, error) {
+	outputTemplate, err := template.New("richerror output").Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return func(e ReadOnlyRichError) string {
+		var outputBuffer bytes.Buffer
+		err := outputTemplate.Execute(&outputBuffer, e)
+		if err != nil {
+			// Error() must never panic so the failure is reported in the output instead.
+			return fmt.Sprintf("[output template failed: %s] %s", err.Error(), e.ToString(ShortOutput))
+		}
+		return outputBuffer.String()
+	}, nil
+}
+
+// SetOutputTemplate compiles tmpl and sets it as the custom output function used by the CustomOutput format.
+// An invalid template returns an error and leaves the current custom output function unchanged.
+func SetOutputTemplate(tmpl string) error {
+	cof, err := TemplateOutputFunc(tmpl)
+	if err != nil {
+		return err
+	}
+	SetCustomOutputFunction(cof)
+	return nil
+}
@@ -0,0 +1,40 @@
+package errors
+
+import "time"
+
+// defaultTimestampFormat is the layout used for timestamps in the text output formats when none is set.
+const defaultTimestampFormat = time.RFC3339Nano
+
+var timestampFormat = defaultTimestampFormat
+
+// SetGlobalTimestampFormat sets the time layout, e.g. time.RFC3339, used for timestamps in the text output formats.
+// Errors created with SetTimestampFormat ignore this setting. An empty layout restores the default of time.RFC3339Nano.
+func SetGlobalTimestampFormat(layout string) {
+	if layout == "" {
+		layout = defaultTimestampFormat
+	}
+	outputSettingsMutex.Lock()
+	defer outputSettingsMutex.Unlock()
+	timestampFormat = layout
+}
+
+// SetTimestampFormat sets the time layout used for timestamps in the text output formats of this error,
+// overriding SetGlobalTimestampFormat. An empty layout uses the global setting again.
+func (e richError) SetTimestampFormat(layout string) RichError {
+	e.timestampFormat = layout
+	return e.withNewID()
+}
+
+// formatTimestamp formats t with the timestamp format of the error, or the global timestamp format when none was set.
+func (e richError) formatTimestamp(t time.Time) string {
+	if e.timestampFormat != "" {
+		return t.Format(e.timestampFormat)
+	}
+	return t.Format(getTimestampFormat())
+}
+
+func getTimestampFormat() string {
+	outputSettingsMutex.RLock()
+	defer outputSettingsMutex.RUnlock()
+	return timestampFormat
+}
@@ -1,5 +1,15 @@
 module github.com/calvine/richerror
 
-go 1.16
+go 1.21
 
-require github.com/spf13/cobra v1.2.1
+require (
+	github.com/pmezard/go-difflib v1.0.0
+	github.com/spf13/cobra v1.2.1
+	golang.org/x/mod v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/inconshreveable/mousetrap v1.0.0 // indirect
+	github.com/spf13/pflag v1.0.5 // indirect
+)
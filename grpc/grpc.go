@@ -0,0 +1,60 @@
+// Package grpc converts rich errors to gRPC statuses. It is a separate module so the errors
+// package does not depend on gRPC.
+package grpc
+
+import (
+	goerrors "errors"
+	"fmt"
+
+	"github.com/calvine/richerror/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorInfoDomain is the domain of the errdetails.ErrorInfo details attached to statuses built by ToGRPCStatus.
+const ErrorInfoDomain = "richerror"
+
+// ToGRPCStatus builds a gRPC status from the first rich error in the error chain. The status code comes from
+// WithGRPCCode and defaults to codes.Unknown, and the message is the error message. An errdetails.ErrorInfo detail
+// with the error code as its reason and the metadata formatted with fmt.Sprint is attached for the error and each
+// of its inner rich errors, with redacted metadata keys recorded as errors.RedactedValue.
+// Errors that do not contain a rich error are converted with status.Convert.
+func ToGRPCStatus(err error) *status.Status {
+	if err == nil {
+		return nil
+	}
+	var richErr errors.ReadOnlyRichError
+	if !goerrors.As(err, &richErr) {
+		return status.Convert(err)
+	}
+	code := codes.Unknown
+	if grpcCode, ok := richErr.GetGRPCCode(); ok {
+		code = codes.Code(grpcCode)
+	}
+	st := status.New(code, richErr.GetErrorMessage())
+	richErr.Walk(func(err errors.ReadOnlyRichError, depth int) bool {
+		// WithDetails only fails for details that can not be marshaled, which ErrorInfo always can.
+		if stWithDetail, detailErr := st.WithDetails(errorInfo(err)); detailErr == nil {
+			st = stWithDetail
+		}
+		return true
+	})
+	return st
+}
+
+func errorInfo(err errors.ReadOnlyRichError) *errdetails.ErrorInfo {
+	metaData := err.GetMetaData()
+	if redactable, ok := err.(interface{ RedactMetaData() errors.RichError }); ok {
+		metaData = redactable.RedactMetaData().GetMetaData()
+	}
+	info := &errdetails.ErrorInfo{
+		Reason:   err.GetErrorCode(),
+		Domain:   ErrorInfoDomain,
+		Metadata: make(map[string]string, len(metaData)),
+	}
+	for key, value := range metaData {
+		info.Metadata[key] = fmt.Sprint(value)
+	}
+	return info
+}
@@ -0,0 +1,67 @@
+package grpc
+
+import (
+	goerrors "errors"
+	"fmt"
+	"testing"
+
+	"github.com/calvine/richerror/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+)
+
+func TestToGRPCStatus(t *testing.T) {
+	innerErr := errors.NewRichError("QueryFailed", "query failed").
+		AddMetaData("table", "users").
+		AddError(goerrors.New("connection reset"))
+	err := errors.NewRichError("UserNotFound", "user not found").
+		WithGRPCCode(errors.GRPCCode(codes.NotFound)).
+		WithRedactedKeys("password").
+		WithMetaData(map[string]interface{}{"userId": "calvine", "password": "hunter2"}).
+		AddError(innerErr)
+	st := ToGRPCStatus(fmt.Errorf("handler failed: %w", err))
+	if st.Code() != codes.NotFound || st.Message() != "user not found" {
+		t.Errorf("status not expected: (expected: NotFound user not found) (actual: %s %s)", st.Code(), st.Message())
+	}
+	expectedDetails := []string{
+		fmt.Sprintf("UserNotFound map[password:%s userId:calvine]", errors.RedactedValue),
+		"QueryFailed map[table:users]",
+	}
+	details := st.Details()
+	if len(details) != len(expectedDetails) {
+		t.Fatalf("status details not expected: (expected: %v) (actual: %v)", expectedDetails, details)
+	}
+	for i, detail := range details {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok {
+			t.Errorf("status detail %d is not an ErrorInfo: %v", i, detail)
+			continue
+		}
+		actual := fmt.Sprintf("%s %v", info.GetReason(), info.GetMetadata())
+		if actual != expectedDetails[i] || info.GetDomain() != ErrorInfoDomain {
+			t.Errorf("status detail %d not expected: (expected: %s) (actual: %s)", i, expectedDetails[i], actual)
+		}
+	}
+}
+
+func TestToGRPCStatusDefaults(t *testing.T) {
+	type testCase struct {
+		name            string
+		err             error
+		expectedCode    codes.Code
+		expectedMessage string
+	}
+	testCases := []testCase{
+		{name: "rich error without code", err: errors.NewRichError("Failed", "something failed"), expectedCode: codes.Unknown, expectedMessage: "something failed"},
+		{name: "plain error", err: goerrors.New("plain failure"), expectedCode: codes.Unknown, expectedMessage: "plain failure"},
+	}
+	for _, tc := range testCases {
+		st := ToGRPCStatus(tc.err)
+		if st.Code() != tc.expectedCode || st.Message() != tc.expectedMessage {
+			t.Errorf("%s test failed: status not expected (expected: %s %s) (actual: %s %s)", tc.name, tc.expectedCode, tc.expectedMessage, st.Code(), st.Message())
+		}
+	}
+	if ToGRPCStatus(nil) != nil {
+		t.Error("nil error test failed: expected a nil status")
+	}
+}
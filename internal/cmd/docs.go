@@ -0,0 +1,165 @@
+/*
+Copyright © 2021 Calvin Echols <calvin.echols@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/calvine/richerror/internal/cmd/models"
+	"github.com/calvine/richerror/internal/templates"
+	"github.com/spf13/cobra"
+)
+
+const (
+	FlagDocsOutFile = "outFile"
+	FlagGroupByTag  = "groupByTag"
+)
+
+// untaggedSectionTitle is the title of the section for errors without tags when the catalog is grouped by tag.
+const untaggedSectionTitle = "Untagged"
+
+// docsCmd represents the docs command
+var (
+	docsDefinitionFile string
+	docsOutFile        string
+	groupByTag         bool
+
+	docsCmd = &cobra.Command{
+		Use:   "docs",
+		Short: "Generates a Markdown catalog of the defined errors.",
+		Long:  `The catalog has a table of every error with its code, message, tags and metadata so support teams and API consumers can browse the errors.`,
+		RunE:  docsGenerator,
+	}
+)
+
+// docsSection is a table of errors in the catalog. The title is empty when the catalog is not grouped by tag.
+type docsSection struct {
+	Title  string
+	Errors []models.ErrorData
+}
+
+func initDocs() {
+	rootCmd.AddCommand(docsCmd)
+
+	docsCmd.Flags().StringVarP(&docsDefinitionFile, FlagErrorsDefinitionFile, "i", "", "The path to the errors definition file to generate the catalog from.")
+	docsCmd.MarkFlagRequired(FlagErrorsDefinitionFile)
+	docsCmd.Flags().StringVarP(&docsOutFile, FlagDocsOutFile, "o", "ERRORS.md", "The file to write the Markdown catalog to. Setting this to 'stdout' will print the catalog to stdout.")
+	docsCmd.Flags().BoolVar(&groupByTag, FlagGroupByTag, false, "Groups the catalog into a table per tag. Errors with several tags are listed under each of them and errors without tags are listed under Untagged.")
+}
+
+func docsGenerator(cmd *cobra.Command, args []string) error {
+	errDataSlice, err := readErrorDefinitions(docsDefinitionFile)
+	if err != nil {
+		return err
+	}
+	docs, err := renderDocs(errDataSlice, groupByTag)
+	if err != nil {
+		return fmt.Errorf("failed to execute docs template: %s", err.Error())
+	}
+	if docsOutFile == "stdout" {
+		fmt.Fprint(os.Stdout, string(docs))
+		return nil
+	}
+	fmt.Printf("Generating error catalog for %d errors -> %s\n", len(errDataSlice), docsOutFile)
+	return ioutil.WriteFile(docsOutFile, docs, fs.ModePerm)
+}
+
+// renderDocs renders a Markdown catalog of the errors in the order they are defined, with a table per tag in sorted
+// order when groupByTag is set.
+func renderDocs(errDataSlice []models.ErrorData, groupByTag bool) ([]byte, error) {
+	funcMap := template.FuncMap{
+		"markdownCell":     markdownCell,
+		"markdownTags":     markdownTags,
+		"markdownMetaData": markdownMetaData,
+	}
+	docsTemplate, err := template.New("Docs template").Funcs(funcMap).Parse(templates.DocsTemplate)
+	if err != nil {
+		return nil, err
+	}
+	sections := []docsSection{{Errors: errDataSlice}}
+	if groupByTag {
+		sections = groupErrorsByTag(errDataSlice)
+	}
+	docsBuffer := bytes.NewBufferString("")
+	err = docsTemplate.Execute(docsBuffer, sections)
+	if err != nil {
+		return nil, err
+	}
+	return docsBuffer.Bytes(), nil
+}
+
+// groupErrorsByTag returns a section per tag in sorted order followed by a section for errors without tags.
+func groupErrorsByTag(errDataSlice []models.ErrorData) []docsSection {
+	errorsByTag := make(map[string][]models.ErrorData)
+	untaggedErrors := make([]models.ErrorData, 0)
+	for _, data := range errDataSlice {
+		seenTags := make(map[string]bool)
+		for _, tag := range data.Tags {
+			tag = strings.TrimSpace(tag)
+			if tag == "" || seenTags[tag] {
+				continue
+			}
+			seenTags[tag] = true
+			errorsByTag[tag] = append(errorsByTag[tag], data)
+		}
+		if len(seenTags) == 0 {
+			untaggedErrors = append(untaggedErrors, data)
+		}
+	}
+	tags := make([]string, 0, len(errorsByTag))
+	for tag := range errorsByTag {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	sections := make([]docsSection, 0, len(tags)+1)
+	for _, tag := range tags {
+		sections = append(sections, docsSection{Title: tag, Errors: errorsByTag[tag]})
+	}
+	if len(untaggedErrors) > 0 {
+		sections = append(sections, docsSection{Title: untaggedSectionTitle, Errors: untaggedErrors})
+	}
+	return sections
+}
+
+// markdownCell escapes a value so it can be used in a Markdown table cell.
+func markdownCell(value string) string {
+	value = strings.ReplaceAll(value, "|", "\\|")
+	return strings.Join(strings.Fields(value), " ")
+}
+
+func markdownTags(tags []string) string {
+	cells := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		cells = append(cells, fmt.Sprintf("`%s`", markdownCell(tag)))
+	}
+	return strings.Join(cells, ", ")
+}
+
+// markdownMetaData lists the metadata of an error as name followed by its data type, e.g. `userId` (`int`).
+func markdownMetaData(items []models.DataItem) string {
+	cells := make([]string, 0, len(items))
+	for _, item := range items {
+		cells = append(cells, fmt.Sprintf("`%s` (`%s`)", markdownCell(item.Name), markdownCell(item.DataType)))
+	}
+	return strings.Join(cells, ", ")
+}
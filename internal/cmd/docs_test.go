@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/calvine/richerror/internal/cmd/models"
+)
+
+func TestRenderDocs(t *testing.T) {
+	type testCase struct {
+		name       string
+		groupByTag bool
+		goldenFile string
+	}
+	errDataSlice, err := readErrorDefinitions("testdata/errors.json")
+	if err != nil {
+		t.Fatalf("failed to read error definitions: %s", err.Error())
+	}
+	testCases := []testCase{
+		{name: "single table", groupByTag: false, goldenFile: "testdata/errors.md"},
+		{name: "grouped by tag", groupByTag: true, goldenFile: "testdata/errors_by_tag.md"},
+	}
+	for _, tc := range testCases {
+		output, err := renderDocs(errDataSlice, tc.groupByTag)
+		if err != nil {
+			t.Fatalf("%s test failed: failed to render docs: %s", tc.name, err.Error())
+		}
+		if *updateGolden {
+			err = ioutil.WriteFile(tc.goldenFile, output, 0644)
+			if err != nil {
+				t.Fatalf("%s test failed: failed to update golden file: %s", tc.name, err.Error())
+			}
+		}
+		expectedOutput, err := ioutil.ReadFile(tc.goldenFile)
+		if err != nil {
+			t.Fatalf("%s test failed: failed to read golden file: %s", tc.name, err.Error())
+		}
+		if string(output) != string(expectedOutput) {
+			t.Errorf("%s test failed: docs output does not match golden file %s: (expected: %s) (actual: %s)", tc.name, tc.goldenFile, expectedOutput, output)
+		}
+	}
+}
+
+func TestMarkdownCell(t *testing.T) {
+	type testCase struct {
+		name     string
+		value    string
+		expected string
+	}
+	testCases := []testCase{
+		{name: "plain value", value: "user not found", expected: "user not found"},
+		{name: "pipe", value: "a|b", expected: "a\\|b"},
+		{name: "new lines", value: "first line\nsecond line", expected: "first line second line"},
+	}
+	for _, tc := range testCases {
+		if actual := markdownCell(tc.value); actual != tc.expected {
+			t.Errorf("%s test failed: cell not expected (expected: %s) (actual: %s)", tc.name, tc.expected, actual)
+		}
+	}
+	items := []models.DataItem{{Name: "userId", DataType: "int"}, {Name: "lookedUpAt", DataType: "time.Time"}}
+	if actual, expected := markdownMetaData(items), "`userId` (`int`), `lookedUpAt` (`time.Time`)"; actual != expected {
+		t.Errorf("metadata cell not expected: (expected: %s) (actual: %s)", expected, actual)
+	}
+}
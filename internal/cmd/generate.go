@@ -19,30 +19,70 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"go/ast"
 	"go/format"
-	"html/template"
+	"go/parser"
+	"go/token"
+	"go/types"
 	"io/fs"
 	"io/ioutil"
 	"os"
 	"path"
 	"strings"
+	"text/template"
 
 	"github.com/calvine/richerror/internal/cmd/models"
 	"github.com/calvine/richerror/internal/cmd/utilities"
 	"github.com/calvine/richerror/internal/templates"
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
+// generatedCodeWarning is included in every generated file so they can be told apart from hand written files.
+const generatedCodeWarning = "This is GENERATED CODE"
+
+// richErrorImportPath is the import path of the errors package every generated constructor file imports.
+const richErrorImportPath = "github.com/calvine/richerror/errors"
+
 const (
 	FlagErrorsDefinitionFile = "errorsDefinitionFile"
 	FlagOutDir               = "outDir"
 	FlagOutputErrorPkg       = "outputErrorPkg"
 	FlagIncludeTags          = "includeTags"
 	FlagExcludeTags          = "excludeTags"
-	// FlagOutputCodePkg        = "outputCodePkg"
+	FlagStrictTags           = "strictTags"
+	FlagEmitHTTPStatusMap    = "emitHTTPStatusMap"
+	FlagEmitRegistry         = "emitRegistry"
+	FlagEmitSentinels        = "emitSentinels"
+	FlagEmitTests            = "emitTests"
+	FlagSingleFile           = "singleFile"
+	FlagCheck                = "check"
+	FlagPrune                = "prune"
+	FlagAutoPascal           = "autoPascal"
+	FlagOutputCodePkg        = "outputCodePkg"
 	// FlagTargetPackage = "targetPkg"
 )
 
+// maxGRPCCode is the largest gRPC status code, Unauthenticated.
+const maxGRPCCode = 16
+
+// outputFormats are the names of the RichErrorOutputFormat constants an error definition can use as its output format.
+var outputFormats = []string{
+	"CustomOutput",
+	"DetailedOutput",
+	"FullOutputFormatted",
+	"FullOutputInline",
+	"ShortDetailedOutput",
+	"ShortOutput",
+	"JSONOutput",
+	"FullOutputRecursive",
+	"ColorOutput",
+	"LogfmtOutput",
+	"JSONInlineOutput",
+	"ShortNoTimeOutput",
+}
+
 // generateCmd represents the generate command
 var (
 	errorsDefinitionFile string
@@ -50,14 +90,27 @@ var (
 	outputErrorPkg       string
 	includeTags          string
 	excludeTags          string
-	// outputCodePkg        string
+	strictTags           bool
+	emitHTTPStatusMap    bool
+	emitRegistry         bool
+	emitSentinels        bool
+	emitTests            bool
+	singleFile           bool
+	check                bool
+	prune                bool
+	autoPascal           bool
+	// outOfDateFiles are the generated files that differ from the files on disk when check is set.
+	outOfDateFiles []string
+	// generatedFiles are the paths of the files generated by the current run, which prune keeps.
+	generatedFiles map[string]bool
+	outputCodePkg  string
 	// targetPkg            string
 
 	generateCmd = &cobra.Command{
 		Use:   "generate",
 		Short: "Generates error constructors and code constants.",
 		Long:  ``,
-		Run:   errorGenerator,
+		RunE:  errorGenerator,
 	}
 )
 
@@ -73,108 +126,672 @@ func initGenerator() {
 	// Cobra supports local flags which will only run when this command
 
 	// This flags are persistent because at soom point other languages could be sub commands to this command.
-	generateCmd.PersistentFlags().StringVarP(&errorsDefinitionFile, FlagErrorsDefinitionFile, "i", "", "The path to the errors definition file to use for error generation.")
+	generateCmd.PersistentFlags().StringVarP(&errorsDefinitionFile, FlagErrorsDefinitionFile, "i", "", "The path to the errors definition file to use for error generation. Files with a .yaml or .yml extension are parsed as YAML, otherwise JSON is expected. If this is a directory every .json, .yaml and .yml file in it is read.")
 	generateCmd.MarkPersistentFlagRequired(FlagErrorsDefinitionFile)
 	generateCmd.PersistentFlags().StringVarP(&outDir, FlagOutDir, "o", ".", "The output path to place the generated files. Setting this to 'stdout' will print the generated files to stdout.")
 	generateCmd.PersistentFlags().StringVarP(&outputErrorPkg, FlagOutputErrorPkg, "e", "errors", "The package to put at the top of the generated error files")
-	generateCmd.PersistentFlags().StringVarP(&includeTags, FlagIncludeTags, "t", "", fmt.Sprintf("Specifies the errors to perform code generation on based on the tags associated with it in the error definion file. Multiple tags are seperated by commas. This is mutually exclusive with %s", FlagExcludeTags))
-	generateCmd.PersistentFlags().StringVarP(&excludeTags, FlagExcludeTags, "x", "", fmt.Sprintf("Specifies the errors to exclude from code generation on based on the tags associated with it in the error definion file. Multiple tags are seperated by commas. This is mutually exclusive with %s", FlagIncludeTags))
-	// generateCmd.Flags().StringVarP(&outputCodePkg, FlagOutputCodePkg, "c", "codes", "The package to put at the top of the generated error code files")
+	generateCmd.PersistentFlags().StringVarP(&includeTags, FlagIncludeTags, "t", "", fmt.Sprintf("Specifies the errors to perform code generation on based on the tags associated with it in the error definion file. Multiple tags are seperated by commas. This is applied before %s", FlagExcludeTags))
+	generateCmd.PersistentFlags().StringVarP(&excludeTags, FlagExcludeTags, "x", "", fmt.Sprintf("Specifies the errors to exclude from code generation on based on the tags associated with it in the error definion file. Multiple tags are seperated by commas. This is applied after %s, removing matching errors from the included errors", FlagIncludeTags))
+	generateCmd.PersistentFlags().BoolVar(&strictTags, FlagStrictTags, false, fmt.Sprintf("Fails generation when a tag in %s or %s does not match a tag of any error definition instead of printing a warning.", FlagIncludeTags, FlagExcludeTags))
+	generateCmd.PersistentFlags().BoolVar(&emitHTTPStatusMap, FlagEmitHTTPStatusMap, false, "Generates an HTTPStatusForCode function mapping error codes to the httpStatus in the error definition file. Unknown codes map to 500.")
+	generateCmd.PersistentFlags().BoolVar(&emitRegistry, FlagEmitRegistry, false, "Generates a Registry map of every generated error code to an ErrorDescriptor with its message, tags, metadata and constructor name.")
+	generateCmd.PersistentFlags().BoolVar(&emitSentinels, FlagEmitSentinels, false, "Generates an Err<Code> sentinel variable for errors that have no metadata and do not include a map.")
+	generateCmd.PersistentFlags().BoolVar(&emitTests, FlagEmitTests, false, "Generates a <code>_test.go file for each error that checks the constructor returns the expected code and tags.")
+	generateCmd.PersistentFlags().BoolVar(&singleFile, FlagSingleFile, false, "Writes every error constructor into a single errors_gen.go file instead of one file per error code.")
+	generateCmd.PersistentFlags().BoolVar(&check, FlagCheck, false, "Generates the code in memory and compares it to the files on disk without writing anything. A diff is printed and the command fails if any file is out of date.")
+	generateCmd.PersistentFlags().BoolVar(&prune, FlagPrune, false, fmt.Sprintf("Removes generated files from the output directory that were not generated by this run, such as files for error codes that are no longer in the error definition file. Only files with the generated code warning are removed. This can not be used with %s or %s.", FlagIncludeTags, FlagExcludeTags))
+	generateCmd.PersistentFlags().BoolVar(&autoPascal, FlagAutoPascal, false, "Converts error codes that are not PascalCase, e.g. user-not-found, to PascalCase with a warning instead of failing generation.")
+	generateCmd.PersistentFlags().StringVarP(&outputCodePkg, FlagOutputCodePkg, "c", "", "Generates the error code constants in a separate package with this name inside the errors package directory, which the errors package imports. The output directory must be inside a Go module so the import path can be determined.")
 }
 
-func errorGenerator(cmd *cobra.Command, args []string) {
+func errorGenerator(cmd *cobra.Command, args []string) error {
 	// fmt.Printf("%s - %s - %s", errorsDefinitionFile, outDir, outputErrorPkg)
 	errorsDir := path.Join(outDir, strings.ToLower(outputErrorPkg))
+	if check && outDir == "stdout" {
+		return fmt.Errorf("%s can not be used when %s is stdout", FlagCheck, FlagOutDir)
+	}
+	// a filtered run only generates some of the defined errors, so pruning would delete the files of the others.
+	if prune && (includeTags != "" || excludeTags != "") {
+		return fmt.Errorf("%s can not be used with %s or %s", FlagPrune, FlagIncludeTags, FlagExcludeTags)
+	}
+	outOfDateFiles = make([]string, 0)
+	generatedFiles = make(map[string]bool)
 	errorsDirExists, _ := utilities.DirExists(errorsDir)
-	if !errorsDirExists {
+	if !errorsDirExists && !check {
 		err := os.MkdirAll(errorsDir, os.ModePerm)
 		if err != nil {
-			panic(err.Error())
+			return err
 		}
 	}
-	// codesDir := path.Join(outDir, strings.ToLower(outputErrorPkg), strings.ToLower(outputCodePkg))
-	funcMap := template.FuncMap{
-		"toUpper":              strings.ToUpper,
-		"toLower":              strings.ToLower,
-		"upperCaseFirstChar":   utilities.UpperCaseFirstChar,
-		"lowerCaseFirstChar":   utilities.LowerCaseFirstChar,
-		"getDataItemImportMap": utilities.GetDataItemImportMap,
+	codesDir := path.Join(outDir, strings.ToLower(outputErrorPkg), strings.ToLower(outputCodePkg))
+	var codeImportPath string
+	if outputCodePkg != "" {
+		codesDirExists, _ := utilities.DirExists(codesDir)
+		if !codesDirExists && !check && outDir != "stdout" {
+			err := os.MkdirAll(codesDir, os.ModePerm)
+			if err != nil {
+				return err
+			}
+		}
+		var err error
+		codeImportPath, err = utilities.GetImportPath(codesDir)
+		if err != nil {
+			return fmt.Errorf("failed to determine the import path for %s: %s", FlagOutputCodePkg, err.Error())
+		}
 	}
-	errConstructorTemplate := template.Must(template.New("Error constructor template").Funcs(funcMap).Parse(templates.ErrorConstructorTemplate))
-	// errCodeTemplate := template.Must(template.New("Error code template").Parse(templates.ErrorCodeTemplate)).Funcs(funcMap)
-	errDataSlice := make([]models.ErrorData, 0)
-	jsonErrorDataFileData, err := ioutil.ReadFile(errorsDefinitionFile)
+	errConstructorTemplate := newErrorConstructorTemplate()
+	errCodeTemplate := template.Must(template.New("Error code template").Funcs(errorTemplateFuncMap()).Parse(templates.ErrorCodeTemplate))
+	errDataSlice, err := readErrorDefinitions(errorsDefinitionFile)
 	if err != nil {
-		errMsg := fmt.Sprintf("failed to open file %s - %s", errorsDefinitionFile, err.Error())
-		panic(errMsg)
+		return err
 	}
-	json.Unmarshal(jsonErrorDataFileData, &errDataSlice)
-	if includeTags != "" {
-		specificTags := strings.Split(includeTags, ",")
-		fmt.Printf("Include tags specified. Filtering error definitions to only generate errors with the following tags: %s\n\n", includeTags)
-		errDataSlice = getMatchingErrorsByTag(errDataSlice, specificTags, true)
-	} else if excludeTags != "" {
-		specificTags := strings.Split(excludeTags, ",")
-		fmt.Printf("Exclude tags specified. Filtering error definitions to only generate errors without the following tags: %s\n\n", excludeTags)
-		errDataSlice = getMatchingErrorsByTag(errDataSlice, specificTags, false)
+	if autoPascal {
+		normalizeErrorCodes(errDataSlice)
+	}
+	err = validateErrorDefinitions(errDataSlice)
+	if err != nil {
+		return err
 	}
+	unmatchedTags := getUnmatchedTags(errDataSlice, includeTags, excludeTags)
+	if len(unmatchedTags) > 0 {
+		if strictTags {
+			return fmt.Errorf("tags do not match any error definition: %s", strings.Join(unmatchedTags, ", "))
+		}
+		fmt.Printf("Warning: tags do not match any error definition: %s\n\n", strings.Join(unmatchedTags, ", "))
+	}
+	errDataSlice = filterErrorDefinitions(errDataSlice, includeTags, excludeTags)
 	fmt.Printf("generating %d errors.\n\n", len(errDataSlice))
+	// failures are collected so every error is attempted before the command exits with an error.
+	failures := make([]string, 0)
+	genDataSlice := make([]models.GeneratorData, 0, len(errDataSlice))
 	for _, data := range errDataSlice {
 		genData := models.GeneratorData{
-			ErrorPkg:  outputErrorPkg,
-			ErrorData: data,
+			ErrorPkg:       outputErrorPkg,
+			CodePkg:        outputCodePkg,
+			CodeImportPath: codeImportPath,
+			EmitSentinel:   emitSentinels,
+			ErrorData:      data,
+		}
+		genDataSlice = append(genDataSlice, genData)
+		if !singleFile && !emitErrorConstructor(errConstructorTemplate, errorsDir, genData) {
+			failures = append(failures, data.Code)
+		}
+		if outputCodePkg != "" {
+			errCode, err := renderErrorCode(errCodeTemplate, genData)
+			if !emitPackageFile(codesDir, fmt.Sprintf("%s.go", strings.ToLower(data.Code)), fmt.Sprintf("%s error code", data.Code), errCode, err) {
+				failures = append(failures, fmt.Sprintf("%s code", data.Code))
+			}
+		}
+		if emitTests {
+			errTestCode, err := renderErrorTest(genData)
+			testFileName := fmt.Sprintf("%s_test.go", strings.ToLower(data.Code))
+			if !emitPackageFile(errorsDir, testFileName, fmt.Sprintf("%s error test", data.Code), errTestCode, err) {
+				failures = append(failures, fmt.Sprintf("%s test", data.Code))
+			}
 		}
-		constructorBuffer := bytes.NewBufferString("")
-		err := errConstructorTemplate.Execute(constructorBuffer, genData)
+	}
+	if singleFile {
+		singleFileCode, err := renderSingleFile(outputErrorPkg, outputCodePkg, codeImportPath, genDataSlice)
+		if !emitPackageFile(errorsDir, "errors_gen.go", "error constructors", singleFileCode, err) {
+			failures = append(failures, "error constructors")
+		}
+	}
+	if emitHTTPStatusMap {
+		httpStatusMapCode, err := renderHTTPStatusMap(outputErrorPkg, errDataSlice)
+		if !emitPackageFile(errorsDir, "httpstatus.go", "HTTP status map", httpStatusMapCode, err) {
+			failures = append(failures, "HTTP status map")
+		}
+	}
+	if emitRegistry {
+		registryCode, err := renderRegistry(outputErrorPkg, errDataSlice)
+		if !emitPackageFile(errorsDir, "registry.go", "error registry", registryCode, err) {
+			failures = append(failures, "error registry")
+		}
+	}
+	// nothing is pruned after a failure so the previous files of errors that failed to generate are kept.
+	if prune && outDir != "stdout" && len(failures) == 0 {
+		err = pruneGeneratedFiles(errorsDir)
+		if err != nil {
+			return err
+		}
+		if outputCodePkg != "" {
+			err = pruneGeneratedFiles(codesDir)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to generate %d of %d errors: %s", len(failures), len(errDataSlice), strings.Join(failures, ", "))
+	}
+	if len(outOfDateFiles) > 0 {
+		return fmt.Errorf("generated code is out of date: %s", strings.Join(outOfDateFiles, ", "))
+	}
+	return nil
+}
+
+func newErrorConstructorTemplate() *template.Template {
+	errConstructorTemplate := template.Must(template.New("Error constructor template").Funcs(errorTemplateFuncMap()).Parse(templates.ErrorConstructorBodyTemplate))
+	return template.Must(errConstructorTemplate.Parse(templates.ErrorConstructorTemplate))
+}
+
+// renderSingleFile renders every error constructor into one file with a single import block.
+func renderSingleFile(errorPkg, codePkg, codeImportPath string, genDataSlice []models.GeneratorData) ([]byte, error) {
+	singleFileTemplate, err := template.New("Single file template").Funcs(errorTemplateFuncMap()).Parse(templates.ErrorConstructorBodyTemplate)
+	if err != nil {
+		return nil, err
+	}
+	singleFileTemplate, err = singleFileTemplate.Parse(templates.SingleFileTemplate)
+	if err != nil {
+		return nil, err
+	}
+	singleFileData := models.SingleFileData{
+		ErrorPkg:       errorPkg,
+		CodePkg:        codePkg,
+		CodeImportPath: codeImportPath,
+		Errors:         make([]models.GeneratorData, 0, len(genDataSlice)),
+	}
+	allMetaData := make([]models.DataItem, 0)
+	for _, genData := range genDataSlice {
+		allMetaData = append(allMetaData, genData.MetaData...)
+		if len(utilities.GetMessagePlaceholders(genData.Message)) > 0 {
+			singleFileData.UsesFmt = true
+		}
+	}
+	// aliases are resolved across every error because they share one import block.
+	fixedImportPaths := constructorImportPaths(singleFileData.UsesFmt, codeImportPath)
+	aliases := utilities.GetImportAliases(allMetaData, fixedImportPaths)
+	singleFileData.Imports = utilities.GetDataItemImports(allMetaData, aliases, fixedImportPaths)
+	for _, genData := range genDataSlice {
+		genData.MetaData, err = utilities.AliasDataItems(genData.MetaData, aliases)
 		if err != nil {
-			fmt.Printf("failed to execute error constructor template: %s\n", err.Error())
+			return nil, err
+		}
+		singleFileData.Errors = append(singleFileData.Errors, genData)
+	}
+	singleFileBuffer := bytes.NewBufferString("")
+	err = singleFileTemplate.Execute(singleFileBuffer, singleFileData)
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(singleFileBuffer.Bytes())
+}
+
+func errorTemplateFuncMap() template.FuncMap {
+	return template.FuncMap{
+		"toUpper":                strings.ToUpper,
+		"toLower":                strings.ToLower,
+		"upperCaseFirstChar":     utilities.UpperCaseFirstChar,
+		"lowerCaseFirstChar":     utilities.LowerCaseFirstChar,
+		"getMessagePlaceholders": utilities.GetMessagePlaceholders,
+		"getMessageExpression":   utilities.GetMessageExpression,
+		"commentText":            utilities.CommentText,
+	}
+}
+
+// emitErrorConstructor writes the constructor file for a single error, or prints it when outDir is stdout.
+// It returns false if the constructor could not be generated.
+func emitErrorConstructor(errConstructorTemplate *template.Template, errorsDir string, genData models.GeneratorData) bool {
+	data := genData.ErrorData
+	errConstructorCode, err := renderErrorConstructor(errConstructorTemplate, genData)
+	if err != nil {
+		fmt.Printf("Failed to generate error constructor for code %s: %s\n", data.Code, err.Error())
+		return false
+	}
+
+	if outDir == "stdout" {
+		fmt.Printf("\n\n************** %s Error Code **************\n\n", data.Code)
+		fmt.Fprint(os.Stdout, string(errConstructorCode))
+		fmt.Printf("\n\n****************************************************")
+	} else {
+		// emit files...
+		fileName := fmt.Sprintf("%s.go", strings.ToLower(data.Code))
+		errConstructorFilePath := path.Join(errorsDir, fileName)
+		fmt.Printf("Generating code for error code: %s -> %s\n", data.Code, errConstructorFilePath)
+		err = writeGeneratedFile(errConstructorFilePath, errConstructorCode)
+		if err != nil {
+			fmt.Printf("Failed to write file %s for err constructor for code %s - %s\n\n\n", errConstructorFilePath, data.Code, err.Error())
+			return false
+		}
+	}
+	return true
+}
+
+// renderErrorConstructor renders the constructor file for a single error.
+func renderErrorConstructor(errConstructorTemplate *template.Template, genData models.GeneratorData) ([]byte, error) {
+	genData, err := withImports(genData, constructorImportPaths(len(utilities.GetMessagePlaceholders(genData.Message)) > 0, genData.CodeImportPath))
+	if err != nil {
+		return nil, err
+	}
+	constructorBuffer := bytes.NewBufferString("")
+	err = errConstructorTemplate.Execute(constructorBuffer, genData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute error constructor template: %w", err)
+	}
+	errConstructorCode, err := format.Source(constructorBuffer.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to run format.Source on error constructor template: %w\n%s", err, constructorBuffer)
+	}
+	return errConstructorCode, nil
+}
+
+// withImports sets the metadata imports of the generator data. Import paths with colliding package names are aliased
+// and the metadata data types are updated to use the aliases. The fixed import paths are the imports the template
+// always writes, which are neither repeated nor given to another import path.
+func withImports(genData models.GeneratorData, fixedImportPaths []string) (models.GeneratorData, error) {
+	aliases := utilities.GetImportAliases(genData.MetaData, fixedImportPaths)
+	genData.Imports = utilities.GetDataItemImports(genData.MetaData, aliases, fixedImportPaths)
+	metaData, err := utilities.AliasDataItems(genData.MetaData, aliases)
+	if err != nil {
+		return genData, err
+	}
+	genData.MetaData = metaData
+	return genData, nil
+}
+
+// constructorImportPaths returns the import paths the constructor and single file templates write themselves.
+func constructorImportPaths(usesFmt bool, codeImportPath string) []string {
+	importPaths := []string{richErrorImportPath}
+	if usesFmt {
+		importPaths = append(importPaths, "fmt")
+	}
+	if codeImportPath != "" {
+		importPaths = append(importPaths, codeImportPath)
+	}
+	return importPaths
+}
+
+// emitPackageFile writes a generated file to the errors package directory, or prints it when outDir is stdout.
+// It returns false if the file could not be generated.
+func emitPackageFile(errorsDir, fileName, description string, code []byte, renderErr error) bool {
+	if renderErr != nil {
+		fmt.Printf("Failed to generate %s: %s\n", description, renderErr.Error())
+		return false
+	}
+	if outDir == "stdout" {
+		fmt.Printf("\n\n************** %s **************\n\n", description)
+		fmt.Fprint(os.Stdout, string(code))
+		fmt.Printf("\n\n****************************************************")
+		return true
+	}
+	filePath := path.Join(errorsDir, fileName)
+	fmt.Printf("Generating %s -> %s\n", description, filePath)
+	err := writeGeneratedFile(filePath, code)
+	if err != nil {
+		fmt.Printf("Failed to write file %s for %s - %s\n\n\n", filePath, description, err.Error())
+		return false
+	}
+	return true
+}
+
+// writeGeneratedFile writes generated code to a file. When check is set nothing is written, instead the code is compared
+// to the file on disk and a unified diff is printed and the file is recorded as out of date if they differ.
+func writeGeneratedFile(filePath string, code []byte) error {
+	generatedFiles[filePath] = true
+	if !check {
+		return ioutil.WriteFile(filePath, code, fs.ModePerm)
+	}
+	existingCode, err := ioutil.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if bytes.Equal(existingCode, code) {
+		return nil
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(existingCode)),
+		B:        difflib.SplitLines(string(code)),
+		FromFile: filePath,
+		ToFile:   fmt.Sprintf("%s (generated)", filePath),
+		Context:  3,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Print(diff)
+	outOfDateFiles = append(outOfDateFiles, filePath)
+	return nil
+}
+
+// pruneGeneratedFiles deletes generated files in errorsDir that were not generated by the current run, such as the files
+// of error codes that are no longer in the definition file or the per error files left behind after switching to a single file.
+// Only files with the generated code warning are deleted so hand written files are left alone.
+// When check is set the files are recorded as out of date instead of being deleted.
+func pruneGeneratedFiles(errorsDir string) error {
+	files, err := ioutil.ReadDir(errorsDir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		filePath := path.Join(errorsDir, file.Name())
+		if file.IsDir() || path.Ext(file.Name()) != ".go" || generatedFiles[filePath] {
 			continue
 		}
-		errConstructorCode, err := format.Source(constructorBuffer.Bytes())
+		code, err := ioutil.ReadFile(filePath)
 		if err != nil {
-			fmt.Printf("%s", constructorBuffer)
-			fmt.Printf("Failed to run format.Source on error code template: %s\n", err.Error())
+			return err
+		}
+		if !bytes.Contains(code, []byte(generatedCodeWarning)) {
+			continue
+		}
+		if check {
+			fmt.Printf("Stale generated file %s should be removed\n", filePath)
+			outOfDateFiles = append(outOfDateFiles, filePath)
 			continue
 		}
+		fmt.Printf("Removing stale generated file %s\n", filePath)
+		err = os.Remove(filePath)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		// codeBuffer := bytes.NewBufferString("")
-		// err = errCodeTemplate.Execute(codeBuffer, genData)
-		// if err != nil {
-		// 	fmt.Printf("failed to execute error code template: %s", err.Error())
-		// 	continue
-		// }
-		// errCodeCode, err := format.Source([]byte(codeBuffer.String()))
-		// if err != nil {
-		// 	fmt.Printf("%s", codeBuffer)
-		// 	fmt.Printf("Failed to run format.Source on error code template: %s", err.Error())
-		// 	continue
-		// }
-
-		if outDir == "stdout" {
-			fmt.Printf("\n\n************** %s Error Code **************\n\n", data.Code)
-			fmt.Fprint(os.Stdout, string(errConstructorCode))
-			fmt.Printf("\n\n****************************************************")
-			// fmt.Printf("\n\n************** %s Error Code Code **************\n\n", data.Code)
-			// fmt.Fprint(os.Stdout, string(errCodeCode))
-			// fmt.Printf("\n\n*********************************************")
-		} else {
-			// emit files...
-			fileName := fmt.Sprintf("%s.go", strings.ToLower(data.Code))
-			errConstructorFilePath := path.Join(errorsDir, fileName)
-			fmt.Printf("Generating code for error code: %s -> %s\n", data.Code, errConstructorFilePath)
-			err = ioutil.WriteFile(errConstructorFilePath, errConstructorCode, fs.ModePerm)
+func renderErrorCode(errCodeTemplate *template.Template, genData models.GeneratorData) ([]byte, error) {
+	codeBuffer := bytes.NewBufferString("")
+	err := errCodeTemplate.Execute(codeBuffer, genData)
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(codeBuffer.Bytes())
+}
+
+func renderErrorTest(genData models.GeneratorData) ([]byte, error) {
+	errTestTemplate, err := template.New("Error test template").Parse(templates.ErrorTestTemplate)
+	if err != nil {
+		return nil, err
+	}
+	genData, err = withImports(genData, []string{"fmt", "testing"})
+	if err != nil {
+		return nil, err
+	}
+	errTestBuffer := bytes.NewBufferString("")
+	err = errTestTemplate.Execute(errTestBuffer, genData)
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(errTestBuffer.Bytes())
+}
+
+func renderHTTPStatusMap(errorPkg string, errDataSlice []models.ErrorData) ([]byte, error) {
+	httpStatusMapTemplate, err := template.New("HTTP status map template").Parse(templates.HTTPStatusMapTemplate)
+	if err != nil {
+		return nil, err
+	}
+	packageData := models.PackageData{
+		ErrorPkg: errorPkg,
+		Errors:   errDataSlice,
+	}
+	httpStatusMapBuffer := bytes.NewBufferString("")
+	err = httpStatusMapTemplate.Execute(httpStatusMapBuffer, packageData)
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(httpStatusMapBuffer.Bytes())
+}
+
+func renderRegistry(errorPkg string, errDataSlice []models.ErrorData) ([]byte, error) {
+	registryTemplate, err := template.New("Registry template").Parse(templates.RegistryTemplate)
+	if err != nil {
+		return nil, err
+	}
+	packageData := models.PackageData{
+		ErrorPkg: errorPkg,
+		Errors:   errDataSlice,
+	}
+	registryBuffer := bytes.NewBufferString("")
+	err = registryTemplate.Execute(registryBuffer, packageData)
+	if err != nil {
+		return nil, err
+	}
+	return format.Source(registryBuffer.Bytes())
+}
+
+// readErrorDefinitions reads the error definitions from a file, or from every .json, .yaml and .yml file in a directory
+// in file name order, concatenating their definitions.
+func readErrorDefinitions(definitionPath string) ([]models.ErrorData, error) {
+	isDir, _ := utilities.DirExists(definitionPath)
+	if !isDir {
+		return readErrorDefinitionFile(definitionPath)
+	}
+	files, err := ioutil.ReadDir(definitionPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s - %s", definitionPath, err.Error())
+	}
+	errDataSlice := make([]models.ErrorData, 0)
+	for _, file := range files {
+		fileExt := strings.ToLower(path.Ext(file.Name()))
+		if file.IsDir() || (fileExt != ".json" && fileExt != ".yaml" && fileExt != ".yml") {
+			continue
+		}
+		fileErrDataSlice, err := readErrorDefinitionFile(path.Join(definitionPath, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		errDataSlice = append(errDataSlice, fileErrDataSlice...)
+	}
+	return errDataSlice, nil
+}
+
+// readErrorDefinitionFile reads the error definition file, parsing it as YAML when it has a .yaml or .yml extension and as JSON otherwise.
+func readErrorDefinitionFile(definitionFile string) ([]models.ErrorData, error) {
+	errDataSlice := make([]models.ErrorData, 0)
+	errorDataFileData, err := ioutil.ReadFile(definitionFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s - %s", definitionFile, err.Error())
+	}
+	switch strings.ToLower(path.Ext(definitionFile)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(errorDataFileData, &errDataSlice)
+	default:
+		err = json.Unmarshal(errorDataFileData, &errDataSlice)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file %s - %s", definitionFile, err.Error())
+	}
+	return errDataSlice, nil
+}
+
+// validateErrorDefinitions checks that every error code is unique and every message placeholder matches a metadata name.
+// Codes are compared case insensitively because each error is written to a file named after its lower cased code.
+func validateErrorDefinitions(errDataSlice []models.ErrorData) error {
+	seenCodes := make(map[string]string)
+	duplicateCodes := make([]string, 0)
+	for _, data := range errDataSlice {
+		err := validateErrorCode(data.Code)
+		if err != nil {
+			return err
+		}
+		err = validateMessagePlaceholders(data)
+		if err != nil {
+			return err
+		}
+		err = validateOutputFormat(data)
+		if err != nil {
+			return err
+		}
+		err = validateStatusCodes(data)
+		if err != nil {
+			return err
+		}
+		for _, item := range data.MetaData {
+			err = validateDataType(item)
 			if err != nil {
-				fmt.Printf("Failed to write file %s for err constructor for code %s - %s\n\n\n", errConstructorFilePath, data.Code, err.Error())
+				return fmt.Errorf("invalid dataType %q for metadata %s of error code %s: %s", item.DataType, item.Name, data.Code, err.Error())
+			}
+		}
+		lowerCode := strings.ToLower(data.Code)
+		if firstCode, ok := seenCodes[lowerCode]; ok {
+			duplicateCodes = append(duplicateCodes, fmt.Sprintf("%s (duplicates %s)", data.Code, firstCode))
+			continue
+		}
+		seenCodes[lowerCode] = data.Code
+	}
+	if len(duplicateCodes) > 0 {
+		return fmt.Errorf("duplicate error codes found in error definitions: %s", strings.Join(duplicateCodes, ", "))
+	}
+	return nil
+}
+
+// validateErrorCode checks that a code is a PascalCase exported Go identifier so it can be used in the generated constant and function names.
+func validateErrorCode(code string) error {
+	if !token.IsIdentifier(code) || !token.IsExported(code) || strings.Contains(code, "_") {
+		return fmt.Errorf("error code %q must be a PascalCase exported Go identifier like %q, use --%s to normalize codes", code, utilities.ToPascalCase(code), FlagAutoPascal)
+	}
+	return nil
+}
+
+// normalizeErrorCodes converts every code to PascalCase, warning about each code that changed.
+func normalizeErrorCodes(errDataSlice []models.ErrorData) {
+	for i, data := range errDataSlice {
+		pascalCode := utilities.ToPascalCase(data.Code)
+		if pascalCode != data.Code {
+			fmt.Printf("Warning: error code %q normalized to %q\n", data.Code, pascalCode)
+			errDataSlice[i].Code = pascalCode
+		}
+	}
+}
+
+// validateStatusCodes checks that the HTTP status and gRPC code of an error are valid when they are provided.
+func validateStatusCodes(data models.ErrorData) error {
+	if data.HTTPStatus != 0 && (data.HTTPStatus < 100 || data.HTTPStatus > 599) {
+		return fmt.Errorf("http status %d for error code %s is not between 100 and 599", data.HTTPStatus, data.Code)
+	}
+	// 0 is the OK code, which is treated as not provided since it is not an error.
+	if data.GRPCCode > maxGRPCCode {
+		return fmt.Errorf("grpc code %d for error code %s is not between 1 and %d", data.GRPCCode, data.Code, maxGRPCCode)
+	}
+	return nil
+}
+
+// validateOutputFormat checks that the output format of an error names one of the RichErrorOutputFormat constants.
+func validateOutputFormat(data models.ErrorData) error {
+	if data.OutputFormat == "" {
+		return nil
+	}
+	for _, format := range outputFormats {
+		if data.OutputFormat == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("output format %q for error code %s is not one of: %s", data.OutputFormat, data.Code, strings.Join(outputFormats, ", "))
+}
+
+func validateMessagePlaceholders(data models.ErrorData) error {
+	for _, placeholder := range utilities.GetMessagePlaceholders(data.Message) {
+		found := false
+		for _, item := range data.MetaData {
+			if item.Name == placeholder {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("message placeholder {%s} for error code %s does not match a metadata name", placeholder, data.Code)
+		}
+	}
+	return nil
+}
+
+// validateDataType checks that a data type is a legal Go type expression. Unqualified type names must be
+// predeclared types like string or exported types, which catches typos like strign before templating.
+// Every package used by a qualified type name, including the element types of composite types, must be imported by the data item.
+func validateDataType(item models.DataItem) error {
+	dataType := item.DataType
+	importedPackages := make(map[string]bool)
+	for _, importPath := range utilities.GetDataItemImportMap([]models.DataItem{item}) {
+		importedPackages[utilities.GetImportPackageName(importPath)] = true
+	}
+	typeExpr, err := parser.ParseExpr(dataType)
+	if err != nil {
+		return err
+	}
+	var typeErr error
+	ast.Inspect(typeExpr, func(node ast.Node) bool {
+		if typeErr != nil {
+			return false
+		}
+		switch expr := node.(type) {
+		case nil:
+			// ast.Inspect calls the function with nil after visiting the children of a node.
+		case *ast.SelectorExpr:
+			// qualified identifiers like time.Time refer to imported packages.
+			if packageIdent, ok := expr.X.(*ast.Ident); ok && !importedPackages[packageIdent.Name] {
+				typeErr = fmt.Errorf("package %s is not imported, add its import path to importPath or importPaths", packageIdent.Name)
+			}
+			return false
+		case *ast.Ident:
+			if _, isTypeName := types.Universe.Lookup(expr.Name).(*types.TypeName); !isTypeName && !expr.IsExported() {
+				typeErr = fmt.Errorf("%s is not a predeclared or exported type", expr.Name)
+			}
+		case *ast.ArrayType, *ast.MapType, *ast.StarExpr, *ast.ChanType, *ast.FuncType, *ast.InterfaceType, *ast.StructType,
+			*ast.FieldList, *ast.Field, *ast.BasicLit, *ast.Ellipsis:
+		default:
+			typeErr = fmt.Errorf("%s is not a type", dataType)
+		}
+		return true
+	})
+	return typeErr
+}
+
+// filterErrorDefinitions applies the include tags first and then removes any remaining errors with an exclude tag,
+// so errors tagged public but not deprecated can be generated with include tags public and exclude tags deprecated.
+func filterErrorDefinitions(errDataSlice []models.ErrorData, includeTags, excludeTags string) []models.ErrorData {
+	if includeTags != "" {
+		specificTags := strings.Split(includeTags, ",")
+		fmt.Printf("Include tags specified. Filtering error definitions to only generate errors with the following tags: %s\n\n", includeTags)
+		errDataSlice = getMatchingErrorsByTag(errDataSlice, specificTags, true)
+	}
+	if excludeTags != "" {
+		specificTags := strings.Split(excludeTags, ",")
+		fmt.Printf("Exclude tags specified. Filtering error definitions to only generate errors without the following tags: %s\n\n", excludeTags)
+		errDataSlice = getMatchingErrorsByTag(errDataSlice, specificTags, false)
+	}
+	return errDataSlice
+}
+
+// getUnmatchedTags returns the tags provided on the command line that do not match a tag of any error definition,
+// which are usually typos that would otherwise silently filter out every error.
+func getUnmatchedTags(errDataSlice []models.ErrorData, tagLists ...string) []string {
+	unmatchedTags := make([]string, 0)
+	for _, tagList := range tagLists {
+		if tagList == "" {
+			continue
+		}
+		for _, cliTag := range strings.Split(tagList, ",") {
+			cliTag = strings.TrimSpace(strings.ToLower(cliTag))
+			if cliTag == "" {
 				continue
 			}
-			// errCodeFilePath := path.Join(codesDir, fileName)
-			// err = ioutil.WriteFile(errCodeFilePath, errCodeCode, fs.ModePerm)
-			// if err != nil {
-			// 	fmt.Printf("Failed to write file %s for err code for code %s", errCodeFilePath, data.Code)
-			// 	continue
-			// }
+			matched := false
+			for _, errDefinition := range errDataSlice {
+				for _, errTag := range errDefinition.Tags {
+					if tagMatches(cliTag, strings.TrimSpace(strings.ToLower(errTag))) {
+						matched = true
+						break
+					}
+				}
+				if matched {
+					break
+				}
+			}
+			if !matched {
+				unmatchedTags = append(unmatchedTags, cliTag)
+			}
 		}
 	}
+	return unmatchedTags
+}
+
+// tagMatches reports whether a tag matches a tag provided on the command line. Tags containing the wildcard
+// characters *, ? or [ are matched as glob patterns, e.g. auth* or *-internal, otherwise tags must be equal.
+func tagMatches(pattern, tag string) bool {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return pattern == tag
+	}
+	matched, err := path.Match(pattern, tag)
+	return err == nil && matched
 }
 
 func getMatchingErrorsByTag(data []models.ErrorData, tags []string, isInclude bool) []models.ErrorData {
@@ -186,7 +803,7 @@ func getMatchingErrorsByTag(data []models.ErrorData, tags []string, isInclude bo
 			errTag = strings.TrimSpace(strings.ToLower(errTag))
 			for _, cliTag := range tags {
 				cliTag = strings.TrimSpace(strings.ToLower(cliTag))
-				if errTag == cliTag {
+				if tagMatches(cliTag, errTag) {
 					firstMatchedTag = errTag
 					hasMatchingTag = true
 					break
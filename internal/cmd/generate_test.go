@@ -0,0 +1,1040 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/calvine/richerror/internal/cmd/models"
+)
+
+// runGeneratedCode writes the provided files into a temporary module and returns the output of go run.
+func runGeneratedCode(t *testing.T, files map[string]string) string {
+	t.Helper()
+	return runGoCommand(t, files, "run", ".")
+}
+
+// runGoCommand writes the provided files into a temporary module and returns the output of the go command.
+func runGoCommand(t *testing.T, files map[string]string, args ...string) string {
+	t.Helper()
+	goBinary, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available to compile generated code")
+	}
+	moduleDir := t.TempDir()
+	// generated error constructors import the errors package so it is replaced with this repository.
+	repoDir, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatalf("failed to find repository root: %s", err.Error())
+	}
+	goSum, err := ioutil.ReadFile(path.Join(repoDir, "go.sum"))
+	if err != nil {
+		t.Fatalf("failed to read go.sum: %s", err.Error())
+	}
+	files["go.mod"] = fmt.Sprintf("module generatedtest\n\ngo 1.18\n\nrequire github.com/calvine/richerror v0.0.0\n\nreplace github.com/calvine/richerror => %s\n", repoDir)
+	files["go.sum"] = string(goSum)
+	for fileName, content := range files {
+		err = os.MkdirAll(path.Dir(path.Join(moduleDir, fileName)), os.ModePerm)
+		if err != nil {
+			t.Fatalf("failed to create directory for %s: %s", fileName, err.Error())
+		}
+		err = ioutil.WriteFile(path.Join(moduleDir, fileName), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("failed to write %s: %s", fileName, err.Error())
+		}
+	}
+	runCmd := exec.Command(goBinary, args...)
+	runCmd.Dir = moduleDir
+	output, err := runCmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("failed to run generated code: %s\n%s", err.Error(), output)
+	}
+	return string(output)
+}
+
+func TestRenderHTTPStatusMap(t *testing.T) {
+	errDataSlice, err := readErrorDefinitions("testdata/errors.json")
+	if err != nil {
+		t.Fatalf("failed to read error definitions: %s", err.Error())
+	}
+	httpStatusMapCode, err := renderHTTPStatusMap("main", errDataSlice)
+	if err != nil {
+		t.Fatalf("failed to render HTTP status map: %s", err.Error())
+	}
+	mainCode := `package main
+
+import "fmt"
+
+func main() {
+	for _, code := range []string{"InvalidType", "NoUserFound", "RepoQueryFailed", "UnknownCode"} {
+		fmt.Printf("%s=%d\n", code, HTTPStatusForCode(code))
+	}
+}
+`
+	output := runGeneratedCode(t, map[string]string{
+		"httpstatus.go": string(httpStatusMapCode),
+		"main.go":       mainCode,
+	})
+	expectedOutput := "InvalidType=400\nNoUserFound=404\nRepoQueryFailed=500\nUnknownCode=500\n"
+	if strings.TrimSpace(output) != strings.TrimSpace(expectedOutput) {
+		t.Errorf("HTTP status lookups not expected: (expected: %s) (actual: %s)", expectedOutput, output)
+	}
+}
+
+func TestReadErrorDefinitionsYAML(t *testing.T) {
+	jsonErrDataSlice, err := readErrorDefinitions("testdata/errors.json")
+	if err != nil {
+		t.Fatalf("failed to read JSON error definitions: %s", err.Error())
+	}
+	yamlErrDataSlice, err := readErrorDefinitions("testdata/errors.yaml")
+	if err != nil {
+		t.Fatalf("failed to read YAML error definitions: %s", err.Error())
+	}
+	if !reflect.DeepEqual(jsonErrDataSlice, yamlErrDataSlice) {
+		t.Errorf("YAML error definitions do not match JSON error definitions: (expected: %+v) (actual: %+v)", jsonErrDataSlice, yamlErrDataSlice)
+	}
+}
+
+func TestValidateErrorDefinitions(t *testing.T) {
+	type testCase struct {
+		name          string
+		file          string
+		expectedError string
+	}
+	testCases := []testCase{
+		{name: "unique codes", file: "testdata/errors.json"},
+		{name: "duplicate codes", file: "testdata/duplicate_errors.json", expectedError: "duplicate error codes found in error definitions: InvalidTYPE (duplicates InvalidType)"},
+	}
+	for _, tc := range testCases {
+		errDataSlice, err := readErrorDefinitions(tc.file)
+		if err != nil {
+			t.Fatalf("%s test failed: failed to read error definitions: %s", tc.name, err.Error())
+		}
+		err = validateErrorDefinitions(errDataSlice)
+		actualError := ""
+		if err != nil {
+			actualError = err.Error()
+		}
+		if actualError != tc.expectedError {
+			t.Errorf("%s test failed: validation error not expected (expected: %s) (actual: %s)", tc.name, tc.expectedError, actualError)
+		}
+	}
+}
+
+func TestErrorGeneratorFailures(t *testing.T) {
+	errorsDefinitionFile = "testdata/invalid_errors.json"
+	outDir = t.TempDir()
+	outputErrorPkg = "errors"
+	defer func() {
+		errorsDefinitionFile, outDir = "", "."
+	}()
+	err := errorGenerator(generateCmd, nil)
+	expectedError := "failed to generate 1 of 2 errors: BrokenName"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("generator failures test failed: error not expected (expected: %s) (actual: %v)", expectedError, err)
+	}
+	if _, statErr := os.Stat(path.Join(outDir, "errors", "invalidtype.go")); statErr != nil {
+		t.Errorf("generator failures test failed: valid errors should still be generated: %s", statErr.Error())
+	}
+}
+
+func TestRenderRegistry(t *testing.T) {
+	errDataSlice, err := readErrorDefinitions("testdata/errors.json")
+	if err != nil {
+		t.Fatalf("failed to read error definitions: %s", err.Error())
+	}
+	registryCode, err := renderRegistry("main", errDataSlice)
+	if err != nil {
+		t.Fatalf("failed to render registry: %s", err.Error())
+	}
+	mainCode := `package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+func main() {
+	codes := make([]string, 0, len(Registry))
+	for code := range Registry {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		descriptor := Registry[code]
+		fmt.Printf("%s=%s %v %d %d %s\n", code, descriptor.Message, descriptor.Tags, len(descriptor.MetaData), descriptor.HTTPStatus, descriptor.Constructor)
+	}
+	fmt.Printf("known=%t unknown=%t\n", IsKnownErrorCode("InvalidType"), IsKnownErrorCode("UnknownCode"))
+}
+`
+	output := runGeneratedCode(t, map[string]string{
+		"registry.go": string(registryCode),
+		"main.go":     mainCode,
+	})
+	expectedOutput := `InvalidType=invalid type encountered [] 1 400 NewInvalidTypeError
+NoUserFound=no user found for given query [database] 3 404 NewNoUserFoundError
+RepoQueryFailed=repo query failed with error [database] 1 0 NewRepoQueryFailedError
+known=true unknown=false
+`
+	if strings.TrimSpace(output) != strings.TrimSpace(expectedOutput) {
+		t.Errorf("registry output not expected: (expected: %s) (actual: %s)", expectedOutput, output)
+	}
+}
+
+func TestErrorConstructorSentinel(t *testing.T) {
+	errorData := models.ErrorData{
+		Code:    "NotReady",
+		Message: `the "primary" service at C:\data is not ready`,
+		Tags:    []string{"availability", `owner "ops" C:\data`},
+	}
+	errorWithMetaData := models.ErrorData{
+		Code:     "InvalidType",
+		Message:  "invalid type encountered",
+		MetaData: []models.DataItem{{Name: "typeEncountered", DataType: "string"}},
+	}
+	errConstructorTemplate := newErrorConstructorTemplate()
+	files := make(map[string]string)
+	for _, data := range []models.ErrorData{errorData, errorWithMetaData} {
+		constructorCode, err := renderErrorConstructor(errConstructorTemplate, models.GeneratorData{ErrorPkg: "main", EmitSentinel: true, ErrorData: data})
+		if err != nil {
+			t.Fatalf("failed to render error constructor: %s", err.Error())
+		}
+		files[fmt.Sprintf("%s.go", strings.ToLower(data.Code))] = string(constructorCode)
+	}
+	if strings.Contains(files["invalidtype.go"], "ErrInvalidType") {
+		t.Errorf("sentinel should not be generated for errors with metadata: %s", files["invalidtype.go"])
+	}
+	files["main.go"] = `package main
+
+import (
+	goerrors "errors"
+	"fmt"
+)
+
+func main() {
+	err := NewNotReadyError(false)
+	fmt.Printf("is=%t helper=%t tags=%q constructorTags=%q\n", goerrors.Is(err, ErrNotReady), IsNotReadyError(ErrNotReady), ErrNotReady.GetTags(), err.GetTags())
+	fmt.Print(ErrNotReady.GetErrorMessage())
+}
+`
+	output := runGeneratedCode(t, files)
+	expectedTags := fmt.Sprintf("%q", errorData.Tags)
+	expectedOutput := fmt.Sprintf("is=true helper=true tags=%s constructorTags=%s\n%s", expectedTags, expectedTags, errorData.Message)
+	if strings.TrimSpace(output) != expectedOutput {
+		t.Errorf("sentinel output not expected: (expected: %s) (actual: %s)", expectedOutput, output)
+	}
+}
+
+func TestErrorConstructorMessagePlaceholders(t *testing.T) {
+	errorData := models.ErrorData{
+		Code:    "UserNotFound",
+		Message: "user {userId} not found after {attempts} attempts",
+		MetaData: []models.DataItem{
+			{Name: "userId", DataType: "string"},
+			{Name: "attempts", DataType: "int"},
+		},
+	}
+	err := validateErrorDefinitions([]models.ErrorData{errorData})
+	if err != nil {
+		t.Fatalf("placeholders matching metadata names should be valid: %s", err.Error())
+	}
+	constructorCode, err := renderErrorConstructor(newErrorConstructorTemplate(), models.GeneratorData{ErrorPkg: "main", ErrorData: errorData})
+	if err != nil {
+		t.Fatalf("failed to render error constructor: %s", err.Error())
+	}
+	output := runGeneratedCode(t, map[string]string{
+		"usernotfound.go": string(constructorCode),
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(NewUserNotFoundError("calvine", 3, false).GetErrorMessage())
+}
+`,
+	})
+	expectedOutput := "user calvine not found after 3 attempts"
+	if strings.TrimSpace(output) != expectedOutput {
+		t.Errorf("message placeholder output not expected: (expected: %s) (actual: %s)", expectedOutput, output)
+	}
+	errorData.Message = "user {userName} not found"
+	err = validateErrorDefinitions([]models.ErrorData{errorData})
+	expectedError := "message placeholder {userName} for error code UserNotFound does not match a metadata name"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("placeholder validation error not expected: (expected: %s) (actual: %v)", expectedError, err)
+	}
+}
+
+func TestRenderErrorTest(t *testing.T) {
+	errDataSlice, err := readErrorDefinitions("testdata/errors.json")
+	if err != nil {
+		t.Fatalf("failed to read error definitions: %s", err.Error())
+	}
+	errConstructorTemplate := newErrorConstructorTemplate()
+	files := make(map[string]string)
+	for _, data := range errDataSlice {
+		genData := models.GeneratorData{ErrorPkg: "generatedtest", ErrorData: data}
+		constructorCode, err := renderErrorConstructor(errConstructorTemplate, genData)
+		if err != nil {
+			t.Fatalf("failed to render error constructor: %s", err.Error())
+		}
+		files[fmt.Sprintf("%s.go", strings.ToLower(data.Code))] = string(constructorCode)
+		errTestCode, err := renderErrorTest(genData)
+		if err != nil {
+			t.Fatalf("failed to render error test for %s: %s", data.Code, err.Error())
+		}
+		files[fmt.Sprintf("%s_test.go", strings.ToLower(data.Code))] = string(errTestCode)
+	}
+	output := runGoCommand(t, files, "test", "-v", ".")
+	for _, data := range errDataSlice {
+		expected := fmt.Sprintf("--- PASS: TestNew%sError", data.Code)
+		if !strings.Contains(output, expected) {
+			t.Errorf("generated test output missing passing test: (expected: %s) (actual: %s)", expected, output)
+		}
+	}
+}
+
+func TestRenderSingleFile(t *testing.T) {
+	errDataSlice, err := readErrorDefinitions("testdata/errors.json")
+	if err != nil {
+		t.Fatalf("failed to read error definitions: %s", err.Error())
+	}
+	genDataSlice := make([]models.GeneratorData, 0, len(errDataSlice))
+	for _, data := range errDataSlice {
+		genDataSlice = append(genDataSlice, models.GeneratorData{ErrorPkg: "main", ErrorData: data})
+	}
+	singleFileCode, err := renderSingleFile("main", "", "", genDataSlice)
+	if err != nil {
+		t.Fatalf("failed to render single file: %s", err.Error())
+	}
+	if importBlocks := strings.Count(string(singleFileCode), "import ("); importBlocks != 1 {
+		t.Errorf("single file should have one import block: (expected: %d) (actual: %d)", 1, importBlocks)
+	}
+	mainCode := `package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func main() {
+	fmt.Println(NewInvalidTypeError("bool", false).GetErrorCode())
+	fmt.Println(NewNoUserFoundError(1, time.Now(), nil, nil, false).GetErrorCode())
+	fmt.Println(IsRepoQueryFailedError(NewRepoQueryFailedError(nil, false)))
+}
+`
+	output := runGeneratedCode(t, map[string]string{
+		"errors_gen.go": string(singleFileCode),
+		"main.go":       mainCode,
+	})
+	expectedOutput := "InvalidType\nNoUserFound\ntrue"
+	if strings.TrimSpace(output) != expectedOutput {
+		t.Errorf("single file output not expected: (expected: %s) (actual: %s)", expectedOutput, output)
+	}
+}
+
+func TestErrorGeneratorCheck(t *testing.T) {
+	errorsDefinitionFile = "testdata/errors.json"
+	outDir = t.TempDir()
+	outputErrorPkg = "errors"
+	defer func() {
+		errorsDefinitionFile, outDir, check = "", ".", false
+	}()
+	err := errorGenerator(generateCmd, nil)
+	if err != nil {
+		t.Fatalf("failed to generate errors: %s", err.Error())
+	}
+	check = true
+	err = errorGenerator(generateCmd, nil)
+	if err != nil {
+		t.Errorf("check should pass when generated code is up to date: %s", err.Error())
+	}
+	staleFilePath := path.Join(outDir, "errors", "invalidtype.go")
+	err = ioutil.WriteFile(staleFilePath, []byte("package errors\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to modify generated file: %s", err.Error())
+	}
+	err = errorGenerator(generateCmd, nil)
+	expectedError := fmt.Sprintf("generated code is out of date: %s", staleFilePath)
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("check error not expected: (expected: %s) (actual: %v)", expectedError, err)
+	}
+	staleCode, _ := ioutil.ReadFile(staleFilePath)
+	if string(staleCode) != "package errors\n" {
+		t.Errorf("check should not write generated files: %s", staleCode)
+	}
+}
+
+func TestGenerateCommandError(t *testing.T) {
+	defer func() {
+		errorsDefinitionFile, outDir, check = "", ".", false
+		rootCmd.SetArgs(nil)
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+	}()
+	var output bytes.Buffer
+	rootCmd.SetOut(&output)
+	rootCmd.SetErr(&output)
+	rootCmd.SetArgs([]string{"generate", "-i", "testdata/errors.json", "-o", t.TempDir(), "--check"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.HasPrefix(err.Error(), "generated code is out of date: ") {
+		t.Errorf("command error not expected: (expected: %s) (actual: %v)", "generated code is out of date: ...", err)
+	}
+	if output.Len() > 0 {
+		t.Errorf("command should not print the usage or the error itself: %s", output.String())
+	}
+}
+
+func TestErrorGeneratorPrune(t *testing.T) {
+	errorsDefinitionFile = "testdata/errors.json"
+	outDir = t.TempDir()
+	outputErrorPkg = "errors"
+	prune = true
+	defer func() {
+		errorsDefinitionFile, outDir, prune = "", ".", false
+	}()
+	errorsDir := path.Join(outDir, "errors")
+	err := os.MkdirAll(errorsDir, os.ModePerm)
+	if err != nil {
+		t.Fatalf("failed to create errors directory: %s", err.Error())
+	}
+	staleFilePath := path.Join(errorsDir, "removederror.go")
+	handWrittenFilePath := path.Join(errorsDir, "helpers.go")
+	ioutil.WriteFile(staleFilePath, []byte("package errors\n\n/* WARNING: This is GENERATED CODE Please do not edit. */\n"), 0644)
+	ioutil.WriteFile(handWrittenFilePath, []byte("package errors\n"), 0644)
+	err = errorGenerator(generateCmd, nil)
+	if err != nil {
+		t.Fatalf("failed to generate errors: %s", err.Error())
+	}
+	if _, err := os.Stat(staleFilePath); !os.IsNotExist(err) {
+		t.Errorf("stale generated file should be removed: %s", staleFilePath)
+	}
+	for _, fileName := range []string{"helpers.go", "invalidtype.go", "nouserfound.go", "repoqueryfailed.go"} {
+		if _, err := os.Stat(path.Join(errorsDir, fileName)); err != nil {
+			t.Errorf("file should not be removed: %s", fileName)
+		}
+	}
+}
+
+func TestErrorGeneratorPruneSingleFileSwitch(t *testing.T) {
+	type testCase struct {
+		name            string
+		singleFile      bool
+		expectedFiles   []string
+		unexpectedFiles []string
+	}
+	errorsDefinitionFile = "testdata/errors.json"
+	outDir = t.TempDir()
+	outputErrorPkg = "errors"
+	prune = true
+	defer func() {
+		errorsDefinitionFile, outDir, prune, singleFile = "", ".", false, false
+	}()
+	errorsDir := path.Join(outDir, "errors")
+	perErrorFiles := []string{"invalidtype.go", "nouserfound.go", "repoqueryfailed.go"}
+	testCases := []testCase{
+		{name: "per error files", singleFile: false, expectedFiles: perErrorFiles, unexpectedFiles: []string{"errors_gen.go"}},
+		{name: "switch to single file", singleFile: true, expectedFiles: []string{"errors_gen.go"}, unexpectedFiles: perErrorFiles},
+		{name: "switch back to per error files", singleFile: false, expectedFiles: perErrorFiles, unexpectedFiles: []string{"errors_gen.go"}},
+	}
+	for _, tc := range testCases {
+		singleFile = tc.singleFile
+		err := errorGenerator(generateCmd, nil)
+		if err != nil {
+			t.Fatalf("%s test failed: failed to generate errors: %s", tc.name, err.Error())
+		}
+		for _, fileName := range tc.expectedFiles {
+			if _, err := os.Stat(path.Join(errorsDir, fileName)); err != nil {
+				t.Errorf("%s test failed: file should exist: %s", tc.name, fileName)
+			}
+		}
+		for _, fileName := range tc.unexpectedFiles {
+			if _, err := os.Stat(path.Join(errorsDir, fileName)); !os.IsNotExist(err) {
+				t.Errorf("%s test failed: stale generated file should be removed: %s", tc.name, fileName)
+			}
+		}
+	}
+}
+
+func TestErrorGeneratorPruneWithTagFilters(t *testing.T) {
+	type testCase struct {
+		name        string
+		includeTags string
+		excludeTags string
+	}
+	errorsDefinitionFile = "testdata/errors.json"
+	outDir = t.TempDir()
+	outputErrorPkg = "errors"
+	prune = true
+	defer func() {
+		errorsDefinitionFile, outDir, prune, includeTags, excludeTags = "", ".", false, "", ""
+	}()
+	errorsDir := path.Join(outDir, "errors")
+	err := os.MkdirAll(errorsDir, os.ModePerm)
+	if err != nil {
+		t.Fatalf("failed to create errors directory: %s", err.Error())
+	}
+	generatedFilePath := path.Join(errorsDir, "nouserfound.go")
+	ioutil.WriteFile(generatedFilePath, []byte("package errors\n\n/* WARNING: This is GENERATED CODE Please do not edit. */\n"), 0644)
+	expectedError := fmt.Sprintf("%s can not be used with %s or %s", FlagPrune, FlagIncludeTags, FlagExcludeTags)
+	testCases := []testCase{
+		{name: "include tags", includeTags: "database"},
+		{name: "exclude tags", excludeTags: "database"},
+	}
+	for _, tc := range testCases {
+		includeTags, excludeTags = tc.includeTags, tc.excludeTags
+		err := errorGenerator(generateCmd, nil)
+		if err == nil || err.Error() != expectedError {
+			t.Errorf("%s test failed: error not expected (expected: %s) (actual: %v)", tc.name, expectedError, err)
+		}
+		if _, err := os.Stat(generatedFilePath); err != nil {
+			t.Errorf("%s test failed: generated file should not be removed: %s", tc.name, generatedFilePath)
+		}
+	}
+}
+
+func TestValidateDataType(t *testing.T) {
+	type testCase struct {
+		name          string
+		item          models.DataItem
+		expectedError string
+	}
+	testCases := []testCase{
+		{name: "predeclared type", item: models.DataItem{DataType: "string"}},
+		{name: "qualified type", item: models.DataItem{DataType: "time.Time", ImportPath: "time"}},
+		{name: "composite type", item: models.DataItem{DataType: "map[string][]*time.Time", ImportPath: "time"}},
+		{name: "composite type with multiple imports", item: models.DataItem{DataType: "map[netip.Addr][]*url.URL", ImportPaths: []string{"net/netip", "net/url"}}},
+		{name: "versioned import path", item: models.DataItem{DataType: "yaml.Node", ImportPath: "gopkg.in/yaml.v3"}},
+		{name: "error type", item: models.DataItem{DataType: "error"}},
+		{name: "interface type", item: models.DataItem{DataType: "interface{}"}},
+		{name: "exported type", item: models.DataItem{DataType: "UserID"}},
+		{name: "misspelled type", item: models.DataItem{DataType: "strign"}, expectedError: "strign is not a predeclared or exported type"},
+		{name: "invalid syntax", item: models.DataItem{DataType: "map[string[int]"}, expectedError: "1:16: expected ']', found newline"},
+		{name: "not a type", item: models.DataItem{DataType: "1 + 2"}, expectedError: "1 + 2 is not a type"},
+		{name: "missing element import", item: models.DataItem{DataType: "map[netip.Addr]time.Time", ImportPath: "time"}, expectedError: "package netip is not imported, add its import path to importPath or importPaths"},
+	}
+	for _, tc := range testCases {
+		err := validateDataType(tc.item)
+		actualError := ""
+		if err != nil {
+			actualError = err.Error()
+		}
+		if actualError != tc.expectedError {
+			t.Errorf("%s test failed: validation error not expected (expected: %s) (actual: %s)", tc.name, tc.expectedError, actualError)
+		}
+	}
+}
+
+func TestValidateErrorCode(t *testing.T) {
+	type testCase struct {
+		name          string
+		code          string
+		expectedError string
+	}
+	testCases := []testCase{
+		{name: "pascal case", code: "UserNotFound"},
+		{name: "acronym", code: "HTTPTimeout"},
+		{name: "lower case", code: "userNotFound", expectedError: `error code "userNotFound" must be a PascalCase exported Go identifier like "UserNotFound", use --autoPascal to normalize codes`},
+		{name: "hyphenated", code: "User-Not-Found", expectedError: `error code "User-Not-Found" must be a PascalCase exported Go identifier like "UserNotFound", use --autoPascal to normalize codes`},
+		{name: "snake case", code: "User_Not_Found", expectedError: `error code "User_Not_Found" must be a PascalCase exported Go identifier like "UserNotFound", use --autoPascal to normalize codes`},
+	}
+	for _, tc := range testCases {
+		err := validateErrorCode(tc.code)
+		actualError := ""
+		if err != nil {
+			actualError = err.Error()
+		}
+		if actualError != tc.expectedError {
+			t.Errorf("%s test failed: validation error not expected (expected: %s) (actual: %s)", tc.name, tc.expectedError, actualError)
+		}
+	}
+}
+
+func TestReadErrorDefinitionsDirectory(t *testing.T) {
+	errDataSlice, err := readErrorDefinitions("testdata/errors_dir")
+	if err != nil {
+		t.Fatalf("failed to read error definitions directory: %s", err.Error())
+	}
+	codes := make([]string, 0, len(errDataSlice))
+	for _, data := range errDataSlice {
+		codes = append(codes, data.Code)
+	}
+	expectedCodes := "InvalidToken,PaymentDeclined"
+	if actualCodes := strings.Join(codes, ","); actualCodes != expectedCodes {
+		t.Errorf("error definitions read from directory not expected: (expected: %s) (actual: %s)", expectedCodes, actualCodes)
+	}
+	err = validateErrorDefinitions(append(errDataSlice, models.ErrorData{Code: "InvalidToken", Message: "duplicate"}))
+	if err == nil {
+		t.Errorf("duplicate codes across definition files should fail validation")
+	}
+}
+
+func TestFilterErrorDefinitions(t *testing.T) {
+	type testCase struct {
+		name          string
+		includeTags   string
+		excludeTags   string
+		expectedCodes string
+	}
+	errDataSlice := []models.ErrorData{
+		{Code: "PublicError", Tags: []string{"public"}},
+		{Code: "DeprecatedPublicError", Tags: []string{"public", "deprecated"}},
+		{Code: "InternalError", Tags: []string{"internal"}},
+		{Code: "DeprecatedInternalError", Tags: []string{"internal", "Deprecated"}},
+	}
+	testCases := []testCase{
+		{name: "no filters", expectedCodes: "PublicError,DeprecatedPublicError,InternalError,DeprecatedInternalError"},
+		{name: "include only", includeTags: "public", expectedCodes: "PublicError,DeprecatedPublicError"},
+		{name: "exclude only", excludeTags: "deprecated", expectedCodes: "PublicError,InternalError"},
+		{name: "include and exclude", includeTags: "public", excludeTags: "deprecated", expectedCodes: "PublicError"},
+		{name: "multiple include and exclude", includeTags: "public, internal", excludeTags: "deprecated", expectedCodes: "PublicError,InternalError"},
+	}
+	for _, tc := range testCases {
+		codes := make([]string, 0)
+		for _, data := range filterErrorDefinitions(errDataSlice, tc.includeTags, tc.excludeTags) {
+			codes = append(codes, data.Code)
+		}
+		if actualCodes := strings.Join(codes, ","); actualCodes != tc.expectedCodes {
+			t.Errorf("%s test failed: filtered errors not expected (expected: %s) (actual: %s)", tc.name, tc.expectedCodes, actualCodes)
+		}
+	}
+}
+
+func TestTagMatches(t *testing.T) {
+	type testCase struct {
+		name     string
+		pattern  string
+		tag      string
+		expected bool
+	}
+	testCases := []testCase{
+		{name: "exact match", pattern: "auth", tag: "auth", expected: true},
+		{name: "exact mismatch", pattern: "auth", tag: "authz", expected: false},
+		{name: "prefix wildcard", pattern: "auth*", tag: "authz", expected: true},
+		{name: "suffix wildcard", pattern: "*-internal", tag: "billing-internal", expected: true},
+		{name: "suffix wildcard mismatch", pattern: "*-internal", tag: "billing-public", expected: false},
+		{name: "single character wildcard", pattern: "v?", tag: "v2", expected: true},
+		{name: "invalid pattern", pattern: "[auth", tag: "auth", expected: false},
+	}
+	for _, tc := range testCases {
+		if actual := tagMatches(tc.pattern, tc.tag); actual != tc.expected {
+			t.Errorf("%s test failed: match result not expected (expected: %t) (actual: %t)", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestErrorGeneratorCodePackage(t *testing.T) {
+	errorsDefinitionFile = "testdata/errors.json"
+	outDir = t.TempDir()
+	outputErrorPkg = "errors"
+	outputCodePkg = "codes"
+	defer func() {
+		errorsDefinitionFile, outDir, outputCodePkg = "", ".", ""
+	}()
+	err := ioutil.WriteFile(path.Join(outDir, "go.mod"), []byte("module generatedtest\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write go.mod: %s", err.Error())
+	}
+	err = errorGenerator(generateCmd, nil)
+	if err != nil {
+		t.Fatalf("failed to generate errors: %s", err.Error())
+	}
+	files := make(map[string]string)
+	err = filepath.Walk(outDir, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || path.Ext(filePath) != ".go" {
+			return err
+		}
+		code, err := ioutil.ReadFile(filePath)
+		relativePath, _ := filepath.Rel(outDir, filePath)
+		files[relativePath] = string(code)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("failed to read generated files: %s", err.Error())
+	}
+	if strings.Contains(files["errors/codes/invalidtype.go"], "errors.") {
+		t.Errorf("codes package should only contain error code constants: %s", files["errors/codes/invalidtype.go"])
+	}
+	files["main.go"] = `package main
+
+import (
+	"fmt"
+
+	"generatedtest/errors"
+	"generatedtest/errors/codes"
+)
+
+func main() {
+	fmt.Println(codes.ErrCodeInvalidType, errors.NewInvalidTypeError("bool", false).GetErrorCode() == codes.ErrCodeInvalidType)
+}
+`
+	output := runGeneratedCode(t, files)
+	expectedOutput := "InvalidType true"
+	if strings.TrimSpace(output) != expectedOutput {
+		t.Errorf("code package output not expected: (expected: %s) (actual: %s)", expectedOutput, output)
+	}
+}
+
+func TestErrorConstructorCompositeTypes(t *testing.T) {
+	errDataSlice, err := readErrorDefinitions("testdata/composite_errors.json")
+	if err != nil {
+		t.Fatalf("failed to read error definitions: %s", err.Error())
+	}
+	err = validateErrorDefinitions(errDataSlice)
+	if err != nil {
+		t.Fatalf("composite error definitions should be valid: %s", err.Error())
+	}
+	files := make(map[string]string)
+	for _, data := range errDataSlice {
+		constructorCode, err := renderErrorConstructor(newErrorConstructorTemplate(), models.GeneratorData{ErrorPkg: "main", ErrorData: data})
+		if err != nil {
+			t.Fatalf("failed to render error constructor: %s", err.Error())
+		}
+		files[fmt.Sprintf("%s.go", strings.ToLower(data.Code))] = string(constructorCode)
+	}
+	files["main.go"] = `package main
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"time"
+)
+
+func main() {
+	endpoint, _ := url.Parse("https://example.com")
+	addr := netip.MustParseAddr("127.0.0.1")
+	err := NewEndpointsUnreachableError([]*url.URL{endpoint}, map[netip.Addr][]time.Time{addr: {time.Unix(0, 0).UTC()}}, false)
+	endpoints, _ := err.GetMetaDataItem("endpoints")
+	attempts, _ := err.GetMetaDataItem("attemptsByAddr")
+	fmt.Println(endpoints.([]*url.URL)[0].Host, attempts.(map[netip.Addr][]time.Time)[addr][0].Year())
+}
+`
+	output := runGeneratedCode(t, files)
+	expectedOutput := "example.com 1970"
+	if strings.TrimSpace(output) != expectedOutput {
+		t.Errorf("composite metadata output not expected: (expected: %s) (actual: %s)", expectedOutput, output)
+	}
+}
+
+func TestErrorConstructorCollidingImports(t *testing.T) {
+	errorData := models.ErrorData{
+		Code:    "TemplateRenderFailed",
+		Message: "template render failed",
+		MetaData: []models.DataItem{
+			{Name: "textTemplate", DataType: "*template.Template", ImportPath: "text/template"},
+			{Name: "htmlTemplates", DataType: "map[string]*template.Template", ImportPath: "html/template"},
+		},
+	}
+	err := validateErrorDefinitions([]models.ErrorData{errorData})
+	if err != nil {
+		t.Fatalf("colliding import definitions should be valid: %s", err.Error())
+	}
+	constructorCode, err := renderErrorConstructor(newErrorConstructorTemplate(), models.GeneratorData{ErrorPkg: "main", ErrorData: errorData})
+	if err != nil {
+		t.Fatalf("failed to render error constructor: %s", err.Error())
+	}
+	expectedParams := "textTemplate *template2.Template, htmlTemplates map[string]*template.Template"
+	if !strings.Contains(string(constructorCode), expectedParams) {
+		t.Errorf("constructor parameters not expected: (expected: %s) (actual: %s)", expectedParams, constructorCode)
+	}
+	singleFileCode, err := renderSingleFile("main", "", "", []models.GeneratorData{{ErrorPkg: "main", ErrorData: errorData}})
+	if err != nil {
+		t.Fatalf("failed to render single file: %s", err.Error())
+	}
+	mainCode := `package main
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+func main() {
+	err := NewTemplateRenderFailedError(texttemplate.New("text"), map[string]*htmltemplate.Template{"page": htmltemplate.New("html")}, false)
+	textTemplate, _ := err.GetMetaDataItem("textTemplate")
+	htmlTemplates, _ := err.GetMetaDataItem("htmlTemplates")
+	fmt.Println(textTemplate.(*texttemplate.Template).Name(), htmlTemplates.(map[string]*htmltemplate.Template)["page"].Name())
+}
+`
+	expectedOutput := "text html"
+	for name, code := range map[string][]byte{"constructor": constructorCode, "single file": singleFileCode} {
+		output := runGeneratedCode(t, map[string]string{
+			"templaterenderfailed.go": string(code),
+			"main.go":                 mainCode,
+		})
+		if strings.TrimSpace(output) != expectedOutput {
+			t.Errorf("%s colliding import output not expected: (expected: %s) (actual: %s)", name, expectedOutput, output)
+		}
+	}
+}
+
+func TestErrorConstructorTemplateImports(t *testing.T) {
+	errorData := models.ErrorData{
+		Code:    "WrapFailed",
+		Message: "failed to wrap {name}",
+		MetaData: []models.DataItem{
+			{Name: "name", DataType: "fmt.Stringer", ImportPath: "fmt"},
+			{Name: "wrapped", DataType: "errors.RichError", ImportPath: "github.com/calvine/richerror/errors"},
+		},
+	}
+	err := validateErrorDefinitions([]models.ErrorData{errorData})
+	if err != nil {
+		t.Fatalf("template import definitions should be valid: %s", err.Error())
+	}
+	genData := models.GeneratorData{ErrorPkg: "main", ErrorData: errorData}
+	constructorCode, err := renderErrorConstructor(newErrorConstructorTemplate(), genData)
+	if err != nil {
+		t.Fatalf("failed to render error constructor: %s", err.Error())
+	}
+	singleFileCode, err := renderSingleFile("main", "", "", []models.GeneratorData{genData})
+	if err != nil {
+		t.Fatalf("failed to render single file: %s", err.Error())
+	}
+	errTestCode, err := renderErrorTest(genData)
+	if err != nil {
+		t.Fatalf("failed to render error test: %s", err.Error())
+	}
+	mainCode := `package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/calvine/richerror/errors"
+)
+
+func main() {
+	err := NewWrapFailedError(net.IPv4(127, 0, 0, 1), errors.NewRichError("InnerCode", "inner message"), false)
+	fmt.Println(err.GetErrorMessage())
+}
+`
+	expectedOutput := "failed to wrap 127.0.0.1"
+	for name, code := range map[string][]byte{"constructor": constructorCode, "single file": singleFileCode} {
+		files := map[string]string{
+			"wrapfailed.go": string(code),
+			"main.go":       mainCode,
+		}
+		if output := runGeneratedCode(t, files); strings.TrimSpace(output) != expectedOutput {
+			t.Errorf("%s template import output not expected: (expected: %s) (actual: %s)", name, expectedOutput, output)
+		}
+		files["wrapfailed_test.go"] = string(errTestCode)
+		runGoCommand(t, files, "test", ".")
+	}
+}
+
+func TestErrorConstructorOutputFormat(t *testing.T) {
+	errorData := models.ErrorData{
+		Code:         "NotAllowed",
+		Message:      "you are not allowed to do that",
+		OutputFormat: "ShortOutput",
+	}
+	err := validateErrorDefinitions([]models.ErrorData{errorData})
+	if err != nil {
+		t.Fatalf("known output format should be valid: %s", err.Error())
+	}
+	constructorCode, err := renderErrorConstructor(newErrorConstructorTemplate(), models.GeneratorData{ErrorPkg: "main", EmitSentinel: true, ErrorData: errorData})
+	if err != nil {
+		t.Fatalf("failed to render error constructor: %s", err.Error())
+	}
+	output := runGeneratedCode(t, map[string]string{
+		"notallowed.go": string(constructorCode),
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"github.com/calvine/richerror/errors"
+)
+
+func main() {
+	err := NewNotAllowedError(false)
+	fmt.Println(err.Error() == err.ToString(errors.ShortOutput), ErrNotAllowed.Error() == ErrNotAllowed.ToString(errors.ShortOutput))
+}
+`,
+	})
+	expectedOutput := "true true"
+	if strings.TrimSpace(output) != expectedOutput {
+		t.Errorf("output format output not expected: (expected: %s) (actual: %s)", expectedOutput, output)
+	}
+	errorData.OutputFormat = "ShortOuptut"
+	err = validateErrorDefinitions([]models.ErrorData{errorData})
+	expectedError := fmt.Sprintf("output format %q for error code NotAllowed is not one of: %s", "ShortOuptut", strings.Join(outputFormats, ", "))
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("output format validation error not expected: (expected: %s) (actual: %v)", expectedError, err)
+	}
+}
+
+func TestErrorConstructorDescription(t *testing.T) {
+	errorData := models.ErrorData{
+		Code:        "UserNotFound",
+		Message:     "user not found",
+		Description: "UserNotFound is returned when no user matches the id.\nCheck the id before retrying.",
+	}
+	constructorCode, err := renderErrorConstructor(newErrorConstructorTemplate(), models.GeneratorData{ErrorPkg: "main", ErrorData: errorData})
+	if err != nil {
+		t.Fatalf("failed to render error constructor: %s", err.Error())
+	}
+	expectedComments := []string{
+		"// ErrCodeUserNotFound UserNotFound is returned when no user matches the id.\n// Check the id before retrying.\nconst ErrCodeUserNotFound",
+		"// NewUserNotFoundError creates a new UserNotFound error. UserNotFound is returned when no user matches the id.\n// Check the id before retrying.\nfunc NewUserNotFoundError",
+		`errors.NewRichError(ErrCodeUserNotFound, msg)`,
+		`msg := "user not found"`,
+	}
+	for _, expected := range expectedComments {
+		if !strings.Contains(string(constructorCode), expected) {
+			t.Errorf("constructor missing description comment: (expected: %s) (actual: %s)", expected, constructorCode)
+		}
+	}
+	errorData.Description = ""
+	constructorCode, err = renderErrorConstructor(newErrorConstructorTemplate(), models.GeneratorData{ErrorPkg: "main", ErrorData: errorData})
+	if err != nil {
+		t.Fatalf("failed to render error constructor: %s", err.Error())
+	}
+	expected := "// ErrCodeUserNotFound user not found\nconst ErrCodeUserNotFound"
+	if !strings.Contains(string(constructorCode), expected) {
+		t.Errorf("constructor should fall back to the message comment: (expected: %s) (actual: %s)", expected, constructorCode)
+	}
+}
+
+func TestErrorConstructorCategory(t *testing.T) {
+	errorData := models.ErrorData{
+		Code:     "NotAllowed",
+		Message:  "you are not allowed to do that",
+		Category: "auth",
+	}
+	constructorCode, err := renderErrorConstructor(newErrorConstructorTemplate(), models.GeneratorData{ErrorPkg: "main", EmitSentinel: true, ErrorData: errorData})
+	if err != nil {
+		t.Fatalf("failed to render error constructor: %s", err.Error())
+	}
+	output := runGeneratedCode(t, map[string]string{
+		"notallowed.go": string(constructorCode),
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	category, _ := NewNotAllowedError(false).GetCategory()
+	sentinelCategory, _ := ErrNotAllowed.GetCategory()
+	fmt.Println(category, sentinelCategory)
+}
+`,
+	})
+	expectedOutput := "auth auth"
+	if strings.TrimSpace(output) != expectedOutput {
+		t.Errorf("category output not expected: (expected: %s) (actual: %s)", expectedOutput, output)
+	}
+}
+
+func TestGetUnmatchedTags(t *testing.T) {
+	type testCase struct {
+		name                  string
+		includeTags           string
+		excludeTags           string
+		expectedUnmatchedTags string
+	}
+	errDataSlice := []models.ErrorData{
+		{Code: "PublicError", Tags: []string{"public"}},
+		{Code: "DeprecatedInternalError", Tags: []string{"internal", "Deprecated"}},
+	}
+	testCases := []testCase{
+		{name: "no filters", expectedUnmatchedTags: ""},
+		{name: "matching tags", includeTags: "public, Internal", excludeTags: "deprecated", expectedUnmatchedTags: ""},
+		{name: "matching glob", includeTags: "pub*", expectedUnmatchedTags: ""},
+		{name: "typo in include tags", includeTags: "publc,internal", expectedUnmatchedTags: "publc"},
+		{name: "typos in include and exclude tags", includeTags: "public,auth*", excludeTags: "depracated", expectedUnmatchedTags: "auth*,depracated"},
+	}
+	for _, tc := range testCases {
+		actualUnmatchedTags := strings.Join(getUnmatchedTags(errDataSlice, tc.includeTags, tc.excludeTags), ",")
+		if actualUnmatchedTags != tc.expectedUnmatchedTags {
+			t.Errorf("%s test failed: unmatched tags not expected (expected: %s) (actual: %s)", tc.name, tc.expectedUnmatchedTags, actualUnmatchedTags)
+		}
+	}
+}
+
+func TestErrorGeneratorStrictTags(t *testing.T) {
+	errorsDefinitionFile = "testdata/errors.json"
+	outDir = t.TempDir()
+	outputErrorPkg = "errors"
+	includeTags = "publc"
+	strictTags = true
+	defer func() {
+		errorsDefinitionFile, outDir, includeTags, strictTags = "", ".", "", false
+	}()
+	err := errorGenerator(generateCmd, nil)
+	expectedError := "tags do not match any error definition: publc"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("strict tags error not expected: (expected: %s) (actual: %v)", expectedError, err)
+	}
+	strictTags = false
+	err = errorGenerator(generateCmd, nil)
+	if err != nil {
+		t.Errorf("unmatched tags should only print a warning without %s: %s", FlagStrictTags, err.Error())
+	}
+}
+
+func TestErrorConstructorStatusCodes(t *testing.T) {
+	type testCase struct {
+		name          string
+		httpStatus    int
+		grpcCode      uint32
+		expectedError string
+	}
+	errorData := models.ErrorData{
+		Code:       "UserNotFound",
+		Message:    "user not found",
+		HTTPStatus: 404,
+		GRPCCode:   5,
+	}
+	constructorCode, err := renderErrorConstructor(newErrorConstructorTemplate(), models.GeneratorData{ErrorPkg: "main", EmitSentinel: true, ErrorData: errorData})
+	if err != nil {
+		t.Fatalf("failed to render error constructor: %s", err.Error())
+	}
+	output := runGeneratedCode(t, map[string]string{
+		"usernotfound.go": string(constructorCode),
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	httpStatus, _ := NewUserNotFoundError(false).GetHTTPStatus()
+	grpcCode, _ := NewUserNotFoundError(false).GetGRPCCode()
+	sentinelHTTPStatus, _ := ErrUserNotFound.GetHTTPStatus()
+	sentinelGRPCCode, _ := ErrUserNotFound.GetGRPCCode()
+	fmt.Println(httpStatus, grpcCode, sentinelHTTPStatus, sentinelGRPCCode)
+}
+`,
+	})
+	expectedOutput := "404 5 404 5"
+	if strings.TrimSpace(output) != expectedOutput {
+		t.Errorf("status codes output not expected: (expected: %s) (actual: %s)", expectedOutput, output)
+	}
+	testCases := []testCase{
+		{name: "valid codes", httpStatus: 404, grpcCode: 5},
+		{name: "no codes", httpStatus: 0, grpcCode: 0},
+		{name: "http status too small", httpStatus: 99, expectedError: "http status 99 for error code UserNotFound is not between 100 and 599"},
+		{name: "http status too large", httpStatus: 600, expectedError: "http status 600 for error code UserNotFound is not between 100 and 599"},
+		{name: "grpc code too large", grpcCode: 17, expectedError: "grpc code 17 for error code UserNotFound is not between 1 and 16"},
+	}
+	for _, tc := range testCases {
+		errorData.HTTPStatus = tc.httpStatus
+		errorData.GRPCCode = tc.grpcCode
+		err := validateErrorDefinitions([]models.ErrorData{errorData})
+		if tc.expectedError == "" {
+			if err != nil {
+				t.Errorf("%s test failed: unexpected validation error: %s", tc.name, err.Error())
+			}
+			continue
+		}
+		if err == nil || err.Error() != tc.expectedError {
+			t.Errorf("%s test failed: validation error not expected (expected: %s) (actual: %v)", tc.name, tc.expectedError, err)
+		}
+	}
+}
@@ -2,27 +2,70 @@ package models
 
 type DataItem struct {
 	// Name is the name of the parameter added to the error constructor as well as the label added to the parameter in the errors metadata.
-	Name string `json:"name"`
+	Name string `json:"name" yaml:"name"`
 	// DataType is a string that tells the go generator what the type of this field is for the error constructor.
-	DataType string `json:"dataType"`
+	DataType string `json:"dataType" yaml:"dataType"`
 	// ImportPath specifies the import path for the data type to be inserted into the error template.
-	ImportPath string `json:"importPath"`
+	ImportPath string `json:"importPath" yaml:"importPath"`
+	// ImportPaths specifies additional import paths for composite data types that use types from more than one package, e.g. map[uuid.UUID]time.Time.
+	ImportPaths []string `json:"importPaths" yaml:"importPaths"`
 }
 
 type ErrorData struct {
 	// Code is expected to be Pascal Case. Is a preferable unique string code for an error.
-	Code string `json:"code"`
+	Code string `json:"code" yaml:"code"`
 	// Tags are a way of grouping errors together so that the can be target for generation in groups, Also these tags can be used for aggregation in log viewers.
-	Tags []string `json:"tags"`
+	Tags []string `json:"tags" yaml:"tags"`
 	// Message is a string added as the message to the error produced.
-	Message string `json:"message"`
+	Message string `json:"message" yaml:"message"`
+	// Description is developer facing documentation used as the doc comment of the generated constant and constructor. The message is used when it is empty.
+	Description string `json:"description" yaml:"description"`
 	// IncludeMap if true adds a map[string]interface{} to the parameters of a constructor so that a genereic map of data can get added to an error constructor parameters list in addition to any specific data defined in MetaData.
-	IncludeMap bool `json:"includeMap"`
+	IncludeMap bool `json:"includeMap" yaml:"includeMap"`
 	// MetaData is an array of dataItem that lists specific data that should be added to the error constructor, and added to the errors metadata map.
-	MetaData []DataItem `json:"metaData"`
+	MetaData []DataItem `json:"metaData" yaml:"metaData"`
+	// HTTPStatus is the HTTP status code returned to clients for this error. It is set on generated errors with WithHTTPStatus and used when generating OpenAPI documentation and the HTTPStatusForCode function, and defaults to 500 when not provided.
+	HTTPStatus int `json:"httpStatus" yaml:"httpStatus"`
+	// GRPCCode is the gRPC status code returned to clients for this error, e.g. 5 for NotFound. It is set on generated errors with WithGRPCCode.
+	GRPCCode uint32 `json:"grpcCode" yaml:"grpcCode"`
+	// Category is a coarse classification of the error, e.g. validation or auth, set on generated errors with WithCategory.
+	Category string `json:"category" yaml:"category"`
+	// OutputFormat is the name of a RichErrorOutputFormat constant, e.g. ShortOutput, that the error renders with instead of the global output format.
+	OutputFormat string `json:"outputFormat" yaml:"outputFormat"`
 }
 
 type GeneratorData struct {
 	ErrorPkg string
+	// CodePkg is the name of the package the error code constants are generated in. When empty the constants are generated in the errors package.
+	CodePkg string
+	// CodeImportPath is the import path of CodePkg.
+	CodeImportPath string
+	// EmitSentinel adds an Err<Code> sentinel variable when the error has no metadata and does not include a map.
+	EmitSentinel bool
+	// Imports are the metadata imports of the error. Colliding package names are aliased and the metadata data types updated to match.
+	Imports []ImportSpec
 	ErrorData
 }
+
+// ImportSpec is an import in a generated file. Alias is only set when the package name collides with another import.
+type ImportSpec struct {
+	Alias string
+	Path  string
+}
+
+type PackageData struct {
+	ErrorPkg string
+	Errors   []ErrorData
+}
+
+// SingleFileData is used to generate every error constructor in a single file.
+type SingleFileData struct {
+	ErrorPkg       string
+	CodePkg        string
+	CodeImportPath string
+	Errors         []GeneratorData
+	// Imports is the union of the metadata imports of every error.
+	Imports []ImportSpec
+	// UsesFmt is true when any error message has placeholders.
+	UsesFmt bool
+}
@@ -0,0 +1,179 @@
+/*
+Copyright © 2021 Calvin Echols <calvin.echols@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/calvine/richerror/internal/cmd/models"
+	"github.com/calvine/richerror/internal/templates"
+	"github.com/spf13/cobra"
+)
+
+const (
+	FlagOpenAPIOutFile = "outFile"
+)
+
+// openapiCmd represents the openapi command
+var (
+	openAPIDefinitionFile string
+	openAPIOutFile        string
+
+	openapiCmd = &cobra.Command{
+		Use:   "openapi",
+		Short: "Generates OpenAPI schemas and example responses for defined errors.",
+		Long:  ``,
+		RunE:  openAPIGenerator,
+	}
+)
+
+func initOpenAPI() {
+	rootCmd.AddCommand(openapiCmd)
+
+	openapiCmd.Flags().StringVarP(&openAPIDefinitionFile, FlagErrorsDefinitionFile, "i", "", "The path to the errors definition file to use for OpenAPI generation.")
+	openapiCmd.MarkFlagRequired(FlagErrorsDefinitionFile)
+	openapiCmd.Flags().StringVarP(&openAPIOutFile, FlagOpenAPIOutFile, "o", "errors.openapi.yaml", "The file to write the OpenAPI document to. Setting this to 'stdout' will print the document to stdout.")
+}
+
+func openAPIGenerator(cmd *cobra.Command, args []string) error {
+	errDataSlice, err := readErrorDefinitions(openAPIDefinitionFile)
+	if err != nil {
+		return err
+	}
+	openAPIDocument, err := renderOpenAPI(errDataSlice)
+	if err != nil {
+		return fmt.Errorf("failed to execute OpenAPI template: %w", err)
+	}
+	if openAPIOutFile == "stdout" {
+		fmt.Fprint(os.Stdout, string(openAPIDocument))
+		return nil
+	}
+	fmt.Printf("Generating OpenAPI document for %d errors -> %s\n", len(errDataSlice), openAPIOutFile)
+	err = ioutil.WriteFile(openAPIOutFile, openAPIDocument, fs.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to write file %s: %w", openAPIOutFile, err)
+	}
+	return nil
+}
+
+func renderOpenAPI(errDataSlice []models.ErrorData) ([]byte, error) {
+	funcMap := template.FuncMap{
+		"yamlString":       yamlString,
+		"openAPISchema":    openAPISchema,
+		"httpStatus":       httpStatusOrDefault,
+		"nonErrorMetaData": nonErrorMetaData,
+	}
+	openAPITemplate, err := template.New("OpenAPI template").Funcs(funcMap).Parse(templates.OpenAPITemplate)
+	if err != nil {
+		return nil, err
+	}
+	openAPIBuffer := bytes.NewBufferString("")
+	err = openAPITemplate.Execute(openAPIBuffer, errDataSlice)
+	if err != nil {
+		return nil, err
+	}
+	return openAPIBuffer.Bytes(), nil
+}
+
+// yamlString quotes a value as a JSON string, which is always a valid YAML double quoted scalar.
+func yamlString(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
+
+// nonErrorMetaData filters out error typed items which are added as inner errors rather than metadata.
+func nonErrorMetaData(items []models.DataItem) []models.DataItem {
+	metaData := make([]models.DataItem, 0, len(items))
+	for _, item := range items {
+		if item.DataType != "error" {
+			metaData = append(metaData, item)
+		}
+	}
+	return metaData
+}
+
+func httpStatusOrDefault(status int) int {
+	if status == 0 {
+		return http.StatusInternalServerError
+	}
+	return status
+}
+
+// openAPISchema renders the OpenAPI schema for a go data type as YAML lines indented by indent spaces.
+func openAPISchema(dataType string, indent int) string {
+	padding := strings.Repeat(" ", indent)
+	dataType = strings.TrimSpace(dataType)
+	var lines []string
+	switch {
+	case dataType == "string":
+		lines = []string{"type: string"}
+	case dataType == "bool":
+		lines = []string{"type: boolean"}
+	case dataType == "int64" || dataType == "uint64":
+		lines = []string{"type: integer", "format: int64"}
+	case dataType == "int32" || dataType == "uint32" || dataType == "rune":
+		lines = []string{"type: integer", "format: int32"}
+	case dataType == "int" || dataType == "int8" || dataType == "int16" || dataType == "uint" ||
+		dataType == "uint8" || dataType == "uint16" || dataType == "byte" || dataType == "time.Duration":
+		lines = []string{"type: integer"}
+	case dataType == "float32":
+		lines = []string{"type: number", "format: float"}
+	case dataType == "float64":
+		lines = []string{"type: number", "format: double"}
+	case dataType == "time.Time":
+		lines = []string{"type: string", "format: date-time"}
+	case dataType == "[]byte":
+		lines = []string{"type: string", "format: byte"}
+	case strings.HasPrefix(dataType, "[]"):
+		return fmt.Sprintf("%stype: array\n%sitems:\n%s", padding, padding, openAPISchema(dataType[2:], indent+2))
+	case strings.HasPrefix(dataType, "map["):
+		valueType := mapValueType(dataType)
+		return fmt.Sprintf("%stype: object\n%sadditionalProperties:\n%s", padding, padding, openAPISchema(valueType, indent+2))
+	case strings.HasPrefix(dataType, "*"):
+		return fmt.Sprintf("%s\n%snullable: true", openAPISchema(dataType[1:], indent), padding)
+	default:
+		lines = []string{fmt.Sprintf("x-go-type: %s", yamlString(dataType))}
+	}
+	for i, line := range lines {
+		lines[i] = padding + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// mapValueType returns the value type of a map type expression such as map[string][]int.
+func mapValueType(dataType string) string {
+	depth := 0
+	for i, c := range dataType {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return dataType[i+1:]
+			}
+		}
+	}
+	return ""
+}
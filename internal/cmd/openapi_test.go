@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+func TestRenderOpenAPI(t *testing.T) {
+	goldenFile := "testdata/errors.openapi.yaml"
+	errDataSlice, err := readErrorDefinitions("testdata/errors.json")
+	if err != nil {
+		t.Fatalf("failed to read error definitions: %s", err.Error())
+	}
+	output, err := renderOpenAPI(errDataSlice)
+	if err != nil {
+		t.Fatalf("failed to render OpenAPI document: %s", err.Error())
+	}
+	if *updateGolden {
+		err = ioutil.WriteFile(goldenFile, output, 0644)
+		if err != nil {
+			t.Fatalf("failed to update golden file: %s", err.Error())
+		}
+	}
+	expectedOutput, err := ioutil.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %s", err.Error())
+	}
+	if string(output) != string(expectedOutput) {
+		t.Errorf("OpenAPI output does not match golden file %s: (expected: %s) (actual: %s)", goldenFile, expectedOutput, output)
+	}
+}
+
+func TestOpenAPIGeneratorError(t *testing.T) {
+	openAPIDefinitionFile = "testdata/missing.json"
+	defer func() {
+		openAPIDefinitionFile, openAPIOutFile = "", "errors.openapi.yaml"
+	}()
+	if err := openAPIGenerator(openapiCmd, nil); err == nil {
+		t.Error("missing definition file should return an error")
+	}
+	openAPIDefinitionFile = "testdata/errors.json"
+	openAPIOutFile = path.Join(t.TempDir(), "missing", "errors.openapi.yaml")
+	if err := openAPIGenerator(openapiCmd, nil); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unwritable output file should return the wrapped write error: (expected: %s) (actual: %v)", fs.ErrNotExist, err)
+	}
+}
@@ -34,6 +34,10 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	// errors returned by a command are not usage errors, so the usage is not printed for them,
+	// and they are printed once by Execute instead of also being printed by cobra.
+	SilenceUsage:  true,
+	SilenceErrors: true,
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -55,6 +59,9 @@ func init() {
 	// rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 
 	initGenerator()
+	initOpenAPI()
+	initSchema()
+	initDocs()
 }
 
 // initConfig reads in config file and ENV variables if set.
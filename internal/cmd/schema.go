@@ -0,0 +1,157 @@
+/*
+Copyright © 2021 Calvin Echols <calvin.echols@gmail.com>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	FlagSchemaOutFile = "outFile"
+)
+
+// schemaCmd represents the schema command
+var (
+	schemaOutFile string
+
+	schemaCmd = &cobra.Command{
+		Use:   "schema",
+		Short: "Prints a JSON Schema describing the error definition file.",
+		Long: `The root of an error definition file is an array, so the schema can not be referenced with $schema inside it.
+Associate the schema with your definition files in your editor instead to get autocompletion and validation, e.g.
+through the json.schemas setting in VS Code, or a "# yaml-language-server: $schema=<path>" comment in YAML files.`,
+		RunE: schemaGenerator,
+	}
+)
+
+func initSchema() {
+	rootCmd.AddCommand(schemaCmd)
+
+	schemaCmd.Flags().StringVarP(&schemaOutFile, FlagSchemaOutFile, "o", "stdout", "The file to write the JSON Schema to. Setting this to 'stdout' will print the schema to stdout.")
+}
+
+func schemaGenerator(cmd *cobra.Command, args []string) error {
+	schema, err := renderSchema()
+	if err != nil {
+		return err
+	}
+	if schemaOutFile == "stdout" {
+		fmt.Fprint(os.Stdout, string(schema))
+		return nil
+	}
+	fmt.Printf("Generating JSON Schema -> %s\n", schemaOutFile)
+	return ioutil.WriteFile(schemaOutFile, schema, fs.ModePerm)
+}
+
+// renderSchema returns a JSON Schema for the error definition file, which is an array of models.ErrorData.
+func renderSchema() ([]byte, error) {
+	dataItemSchema := map[string]interface{}{
+		"type":                 "object",
+		"required":             []string{"name", "dataType"},
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"name": map[string]interface{}{
+				"type":        "string",
+				"description": "The name of the constructor parameter and the metadata key it is added under.",
+			},
+			"dataType": map[string]interface{}{
+				"type":        "string",
+				"description": "The Go type of the constructor parameter. Parameters of type error are added as inner errors.",
+			},
+			"importPath": map[string]interface{}{
+				"type":        "string",
+				"description": "The import path required for the data type, e.g. time for time.Time.",
+			},
+			"importPaths": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Additional import paths for composite data types that use types from more than one package.",
+			},
+		},
+	}
+	errorDataSchema := map[string]interface{}{
+		"type":                 "object",
+		"required":             []string{"code", "message"},
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"code": map[string]interface{}{
+				"type":        "string",
+				"description": "A unique Pascal case code for the error.",
+			},
+			"tags": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Tags used to group errors for generation and aggregation in log viewers.",
+			},
+			"message": map[string]interface{}{
+				"type":        "string",
+				"description": "The message of the generated error.",
+			},
+			"description": map[string]interface{}{
+				"type":        "string",
+				"description": "Developer facing documentation used as the doc comment of the generated constant and constructor.",
+			},
+			"includeMap": map[string]interface{}{
+				"type":        "boolean",
+				"description": "Adds a map[string]interface{} parameter to the constructor for additional metadata.",
+			},
+			"metaData": map[string]interface{}{
+				"type":        "array",
+				"items":       dataItemSchema,
+				"description": "Specific data added to the constructor parameters and the error metadata.",
+			},
+			"httpStatus": map[string]interface{}{
+				"type":        "integer",
+				"minimum":     100,
+				"maximum":     599,
+				"description": "The HTTP status returned to clients for this error. Defaults to 500.",
+			},
+			"grpcCode": map[string]interface{}{
+				"type":        "integer",
+				"minimum":     1,
+				"maximum":     16,
+				"description": "The gRPC status code returned to clients for this error, e.g. 5 for NotFound.",
+			},
+			"category": map[string]interface{}{
+				"type":        "string",
+				"description": "A coarse classification of the error, e.g. validation or auth, used to group errors with different codes.",
+			},
+			"outputFormat": map[string]interface{}{
+				"type":        "string",
+				"enum":        outputFormats,
+				"description": "The output format the error renders with instead of the global output format.",
+			},
+		},
+	}
+	schema := map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "richerror error definitions",
+		"description": "The error definitions used by richerror to generate error constructors.",
+		"type":        "array",
+		"items":       errorDataSchema,
+	}
+	schemaJSON, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(schemaJSON, '\n'), nil
+}
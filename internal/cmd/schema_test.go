@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/calvine/richerror/internal/cmd/models"
+)
+
+// jsonFieldNames returns the JSON names of the fields of a struct type.
+func jsonFieldNames(structType reflect.Type) []string {
+	names := make([]string, 0, structType.NumField())
+	for i := 0; i < structType.NumField(); i++ {
+		names = append(names, strings.Split(structType.Field(i).Tag.Get("json"), ",")[0])
+	}
+	return names
+}
+
+func TestRenderSchema(t *testing.T) {
+	type schemaObject struct {
+		Properties           map[string]json.RawMessage `json:"properties"`
+		AdditionalProperties bool                       `json:"additionalProperties"`
+		Items                json.RawMessage            `json:"items"`
+	}
+	output, err := renderSchema()
+	if err != nil {
+		t.Fatalf("failed to render schema: %s", err.Error())
+	}
+	var schema schemaObject
+	err = json.Unmarshal(output, &schema)
+	if err != nil {
+		t.Fatalf("failed to parse schema: %s", err.Error())
+	}
+	var errorDataSchema, dataItemSchema schemaObject
+	json.Unmarshal(schema.Items, &errorDataSchema)
+	json.Unmarshal(errorDataSchema.Properties["metaData"], &dataItemSchema)
+	json.Unmarshal(dataItemSchema.Items, &dataItemSchema)
+	for name, objectSchema := range map[string]struct {
+		schema     schemaObject
+		structType reflect.Type
+	}{
+		"error data": {schema: errorDataSchema, structType: reflect.TypeOf(models.ErrorData{})},
+		"data item":  {schema: dataItemSchema, structType: reflect.TypeOf(models.DataItem{})},
+	} {
+		if objectSchema.schema.AdditionalProperties {
+			t.Errorf("%s schema should not allow additional properties", name)
+		}
+		for _, field := range jsonFieldNames(objectSchema.structType) {
+			if _, ok := objectSchema.schema.Properties[field]; !ok {
+				t.Errorf("%s schema is missing property: %s", name, field)
+			}
+		}
+	}
+}
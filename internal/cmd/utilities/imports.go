@@ -0,0 +1,119 @@
+package utilities
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+
+	"github.com/calvine/richerror/internal/cmd/models"
+)
+
+// GetImportAliases returns an alias for every import path used by the data items whose package name is shared with
+// another import path, e.g. two config packages. The first path in sorted order keeps the package name and the others
+// are numbered, so github.com/a/config and github.com/b/config are imported as config and config2. The fixed import
+// paths are imported by the template itself, so their package names are never given to a data item import path and
+// a data item import path that is also a fixed import path is not aliased.
+func GetImportAliases(items []models.DataItem, fixedImportPaths []string) map[string]string {
+	importPaths := getDataItemOnlyImportPaths(items, fixedImportPaths)
+	usedNames := make(map[string]bool)
+	seenNames := make(map[string]bool)
+	for _, importPath := range fixedImportPaths {
+		usedNames[GetImportPackageName(importPath)] = true
+		seenNames[GetImportPackageName(importPath)] = true
+	}
+	for _, importPath := range importPaths {
+		usedNames[GetImportPackageName(importPath)] = true
+	}
+	aliases := make(map[string]string)
+	for _, importPath := range importPaths {
+		packageName := GetImportPackageName(importPath)
+		if !seenNames[packageName] {
+			seenNames[packageName] = true
+			continue
+		}
+		alias := packageName
+		for i := 2; usedNames[alias]; i++ {
+			alias = fmt.Sprintf("%s%d", packageName, i)
+		}
+		usedNames[alias] = true
+		aliases[importPath] = alias
+	}
+	return aliases
+}
+
+// GetDataItemImports returns the sorted unique imports used by the data items with the alias of each import path set.
+// The fixed import paths are left out because the template already imports them.
+func GetDataItemImports(items []models.DataItem, aliases map[string]string, fixedImportPaths []string) []models.ImportSpec {
+	importPaths := getDataItemOnlyImportPaths(items, fixedImportPaths)
+	imports := make([]models.ImportSpec, 0, len(importPaths))
+	for _, importPath := range importPaths {
+		imports = append(imports, models.ImportSpec{Alias: aliases[importPath], Path: importPath})
+	}
+	return imports
+}
+
+// getDataItemOnlyImportPaths returns the sorted unique import paths used by the data items that are not fixed import paths.
+func getDataItemOnlyImportPaths(items []models.DataItem, fixedImportPaths []string) []string {
+	fixedImports := make(map[string]bool)
+	for _, importPath := range fixedImportPaths {
+		fixedImports[importPath] = true
+	}
+	importPaths := make([]string, 0)
+	for _, importPath := range GetDataItemImportMap(items) {
+		if !fixedImports[importPath] {
+			importPaths = append(importPaths, importPath)
+		}
+	}
+	return importPaths
+}
+
+// AliasDataItems returns a copy of the data items with the package qualifiers in their data types replaced by the
+// alias of the import path they refer to, e.g. *config.Settings becomes *config2.Settings.
+func AliasDataItems(items []models.DataItem, aliases map[string]string) ([]models.DataItem, error) {
+	aliasedItems := make([]models.DataItem, len(items))
+	for i, item := range items {
+		dataType, err := aliasDataType(item, aliases)
+		if err != nil {
+			return nil, err
+		}
+		aliasedItems[i] = item
+		aliasedItems[i].DataType = dataType
+	}
+	return aliasedItems, nil
+}
+
+func aliasDataType(item models.DataItem, aliases map[string]string) (string, error) {
+	packageAliases := make(map[string]string)
+	for _, importPath := range GetDataItemImportMap([]models.DataItem{item}) {
+		if alias, ok := aliases[importPath]; ok {
+			packageAliases[GetImportPackageName(importPath)] = alias
+		}
+	}
+	if len(packageAliases) == 0 {
+		return item.DataType, nil
+	}
+	typeExpr, err := parser.ParseExpr(item.DataType)
+	if err != nil {
+		return "", err
+	}
+	ast.Inspect(typeExpr, func(node ast.Node) bool {
+		if selector, ok := node.(*ast.SelectorExpr); ok {
+			if packageIdent, ok := selector.X.(*ast.Ident); ok {
+				if alias, ok := packageAliases[packageIdent.Name]; ok {
+					packageIdent.Name = alias
+				}
+			}
+			return false
+		}
+		return true
+	})
+	dataTypeBuffer := bytes.NewBufferString("")
+	err = format.Node(dataTypeBuffer, token.NewFileSet(), typeExpr)
+	if err != nil {
+		return "", err
+	}
+	return dataTypeBuffer.String(), nil
+}
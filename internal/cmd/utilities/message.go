@@ -0,0 +1,30 @@
+package utilities
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var messagePlaceholderRegex = regexp.MustCompile(`\{(\w+)\}`)
+
+// GetMessagePlaceholders returns the names of the {name} placeholders in a message in the order they appear.
+func GetMessagePlaceholders(message string) []string {
+	placeholders := make([]string, 0)
+	for _, match := range messagePlaceholderRegex.FindAllStringSubmatch(message, -1) {
+		placeholders = append(placeholders, match[1])
+	}
+	return placeholders
+}
+
+// GetMessageExpression returns the Go expression for an error message. Messages without placeholders are a string literal,
+// otherwise the placeholders are substituted with the parameters of the same name using fmt.Sprintf.
+func GetMessageExpression(message string) string {
+	placeholders := GetMessagePlaceholders(message)
+	if len(placeholders) == 0 {
+		return strconv.Quote(message)
+	}
+	format := messagePlaceholderRegex.ReplaceAllString(strings.ReplaceAll(message, "%", "%%"), "%v")
+	return fmt.Sprintf("fmt.Sprintf(%s, %s)", strconv.Quote(format), strings.Join(placeholders, ", "))
+}
@@ -0,0 +1,29 @@
+package utilities
+
+import "testing"
+
+func TestGetMessageExpression(t *testing.T) {
+	testCases := []testCase{
+		{
+			expectedOutput: `"no user found"`,
+			input:          "no user found",
+			name:           "no placeholders",
+		},
+		{
+			expectedOutput: `fmt.Sprintf("user %v not found after %v attempts", userId, attempts)`,
+			input:          "user {userId} not found after {attempts} attempts",
+			name:           "placeholders",
+		},
+		{
+			expectedOutput: `fmt.Sprintf("%v is 100%% \"invalid\"", value)`,
+			input:          `{value} is 100% "invalid"`,
+			name:           "escaped characters",
+		},
+	}
+	for _, test := range testCases {
+		output := GetMessageExpression(test.input)
+		if output != test.expectedOutput {
+			t.Errorf("%s test failed: output not expected: (expected: %s) (actual: %s)", test.name, test.expectedOutput, output)
+		}
+	}
+}
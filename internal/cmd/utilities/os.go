@@ -1,6 +1,13 @@
 package utilities
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+)
 
 // exists returns whether the given file or directory exists
 func Exists(path string) (bool, error) {
@@ -25,3 +32,28 @@ func DirExists(path string) (bool, error) {
 	}
 	return false, err
 }
+
+// GetImportPath returns the Go import path of a directory by finding the go.mod file of the module that contains it.
+func GetImportPath(dir string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for moduleDir := absDir; ; moduleDir = filepath.Dir(moduleDir) {
+		goModData, err := os.ReadFile(filepath.Join(moduleDir, "go.mod"))
+		if err == nil {
+			modulePath := modfile.ModulePath(goModData)
+			if modulePath == "" {
+				return "", fmt.Errorf("no module path found in %s", filepath.Join(moduleDir, "go.mod"))
+			}
+			relativeDir, err := filepath.Rel(moduleDir, absDir)
+			if err != nil {
+				return "", err
+			}
+			return path.Join(modulePath, filepath.ToSlash(relativeDir)), nil
+		}
+		if filepath.Dir(moduleDir) == moduleDir {
+			return "", fmt.Errorf("no go.mod found for %s", absDir)
+		}
+	}
+}
@@ -1,7 +1,15 @@
 package utilities
 
-import "github.com/calvine/richerror/internal/cmd/models"
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
 
+	"github.com/calvine/richerror/internal/cmd/models"
+)
+
+// GetDataItemImportMap returns the sorted unique import paths used by the data items.
 func GetDataItemImportMap(items []models.DataItem) []string {
 	uniqueImportsMap := make(map[string]bool)
 	uniqueImports := make([]string, 0)
@@ -9,9 +17,28 @@ func GetDataItemImportMap(items []models.DataItem) []string {
 		if i.ImportPath != "" {
 			uniqueImportsMap[i.ImportPath] = true
 		}
+		for _, importPath := range i.ImportPaths {
+			uniqueImportsMap[importPath] = true
+		}
 	}
 	for k := range uniqueImportsMap {
 		uniqueImports = append(uniqueImports, k)
 	}
+	sort.Strings(uniqueImports)
 	return uniqueImports
 }
+
+var majorVersionRegex = regexp.MustCompile(`^v[0-9]+$`)
+
+// GetImportPackageName returns the package name conventionally used for an import path, skipping major version
+// suffixes like /v2 and .v3 and go- prefixes, e.g. gopkg.in/yaml.v3 is yaml and github.com/mattn/go-sqlite3 is sqlite3.
+func GetImportPackageName(importPath string) string {
+	packageName := path.Base(importPath)
+	if majorVersionRegex.MatchString(packageName) {
+		packageName = path.Base(path.Dir(importPath))
+	}
+	if extIndex := strings.LastIndex(packageName, "."); extIndex > 0 && majorVersionRegex.MatchString(packageName[extIndex+1:]) {
+		packageName = packageName[:extIndex]
+	}
+	return strings.TrimPrefix(packageName, "go-")
+}
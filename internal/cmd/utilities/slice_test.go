@@ -0,0 +1,104 @@
+package utilities
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/calvine/richerror/internal/cmd/models"
+)
+
+func TestGetImportPackageName(t *testing.T) {
+	testCases := []testCase{
+		{
+			expectedOutput: "time",
+			input:          "time",
+			name:           "standard library",
+		},
+		{
+			expectedOutput: "uuid",
+			input:          "github.com/google/uuid",
+			name:           "module path",
+		},
+		{
+			expectedOutput: "yaml",
+			input:          "gopkg.in/yaml.v3",
+			name:           "gopkg.in version suffix",
+		},
+		{
+			expectedOutput: "chi",
+			input:          "github.com/go-chi/chi/v5",
+			name:           "major version suffix",
+		},
+		{
+			expectedOutput: "sqlite3",
+			input:          "github.com/mattn/go-sqlite3",
+			name:           "go prefix",
+		},
+	}
+	for _, test := range testCases {
+		output := GetImportPackageName(test.input)
+		if output != test.expectedOutput {
+			t.Errorf("%s test failed: output not expected: (expected: %s) (actual: %s)", test.name, test.expectedOutput, output)
+		}
+	}
+}
+
+func TestGetImportAliases(t *testing.T) {
+	items := []models.DataItem{
+		{Name: "appConfig", DataType: "*config.Settings", ImportPath: "github.com/example/app/config"},
+		{Name: "libConfig", DataType: "map[string]config.Option", ImportPath: "github.com/example/lib/config"},
+		{Name: "createdAt", DataType: "time.Time", ImportPath: "time"},
+	}
+	aliases := GetImportAliases(items, nil)
+	expectedAliases := map[string]string{"github.com/example/lib/config": "config2"}
+	if fmt.Sprint(aliases) != fmt.Sprint(expectedAliases) {
+		t.Errorf("aliases not expected: (expected: %v) (actual: %v)", expectedAliases, aliases)
+	}
+	aliasedItems, err := AliasDataItems(items, aliases)
+	if err != nil {
+		t.Fatalf("failed to alias data items: %s", err.Error())
+	}
+	expectedDataTypes := []string{"*config.Settings", "map[string]config2.Option", "time.Time"}
+	for i, item := range aliasedItems {
+		if item.DataType != expectedDataTypes[i] {
+			t.Errorf("%s data type not expected: (expected: %s) (actual: %s)", item.Name, expectedDataTypes[i], item.DataType)
+		}
+	}
+	if items[1].DataType != "map[string]config.Option" {
+		t.Errorf("aliasing should not modify the original data items: %s", items[1].DataType)
+	}
+	imports := GetDataItemImports(items, aliases, nil)
+	expectedImports := "[{ github.com/example/app/config} {config2 github.com/example/lib/config} { time}]"
+	if fmt.Sprint(imports) != expectedImports {
+		t.Errorf("imports not expected: (expected: %s) (actual: %v)", expectedImports, imports)
+	}
+}
+
+func TestGetImportAliasesFixedImports(t *testing.T) {
+	items := []models.DataItem{
+		{Name: "richErr", DataType: "errors.RichError", ImportPath: "github.com/calvine/richerror/errors"},
+		{Name: "stackErr", DataType: "*errors.Frame", ImportPath: "github.com/pkg/errors"},
+		{Name: "createdAt", DataType: "time.Time", ImportPath: "time"},
+	}
+	fixedImportPaths := []string{"github.com/calvine/richerror/errors", "fmt"}
+	aliases := GetImportAliases(items, fixedImportPaths)
+	expectedAliases := map[string]string{"github.com/pkg/errors": "errors2"}
+	if fmt.Sprint(aliases) != fmt.Sprint(expectedAliases) {
+		t.Errorf("aliases not expected: (expected: %v) (actual: %v)", expectedAliases, aliases)
+	}
+	aliasedItems, err := AliasDataItems(items, aliases)
+	if err != nil {
+		t.Fatalf("failed to alias data items: %s", err.Error())
+	}
+	expectedDataTypes := []string{"errors.RichError", "*errors2.Frame", "time.Time"}
+	for i, item := range aliasedItems {
+		if item.DataType != expectedDataTypes[i] {
+			t.Errorf("%s data type not expected: (expected: %s) (actual: %s)", item.Name, expectedDataTypes[i], item.DataType)
+		}
+	}
+	imports := GetDataItemImports(items, aliases, fixedImportPaths)
+	expectedImports := "[{errors2 github.com/pkg/errors} { time}]"
+	if fmt.Sprint(imports) != expectedImports {
+		t.Errorf("imports not expected: (expected: %s) (actual: %v)", expectedImports, imports)
+	}
+}
@@ -1,6 +1,9 @@
 package utilities
 
-import "strings"
+import (
+	"strings"
+	"unicode"
+)
 
 func UpperCaseFirstChar(input string) string {
 	length := len(input)
@@ -33,3 +36,34 @@ func LowerCaseFirstChar(input string) string {
 	}
 	return string(inputBuffer)
 }
+
+// ToPascalCase converts an input like "user-not_found" or "user not found" to "UserNotFound" by removing
+// characters that are not letters or digits and upper casing the first character of each word.
+func ToPascalCase(input string) string {
+	words := strings.FieldsFunc(input, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var outputBuilder strings.Builder
+	for _, word := range words {
+		outputBuilder.WriteString(UpperCaseFirstChar(word))
+	}
+	return outputBuilder.String()
+}
+
+// CommentText prepares text to follow "// " in a generated comment. Surrounding whitespace is trimmed
+// and every line after the first is prefixed with "//" so multi line text stays inside the comment.
+func CommentText(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	var commentBuilder strings.Builder
+	for i, line := range lines {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if i > 0 {
+			commentBuilder.WriteString("\n//")
+			if line != "" {
+				commentBuilder.WriteString(" ")
+			}
+		}
+		commentBuilder.WriteString(line)
+	}
+	return commentBuilder.String()
+}
@@ -49,3 +49,50 @@ func TestLowerCaseFirstChar(t *testing.T) {
 		}
 	}
 }
+
+func TestToPascalCase(t *testing.T) {
+	testCases := []testCase{
+		{
+			expectedOutput: "UserNotFound",
+			input:          "UserNotFound",
+			name:           "already pascal case",
+		},
+		{
+			expectedOutput: "UserNotFound",
+			input:          "user-not_found",
+			name:           "hyphens and underscores",
+		},
+		{
+			expectedOutput: "InvalidHTTPStatus2",
+			input:          "invalid HTTPStatus 2",
+			name:           "spaces and acronyms",
+		},
+	}
+	for _, test := range testCases {
+		output := ToPascalCase(test.input)
+		if output != test.expectedOutput {
+			t.Errorf("%s test failed: output not expected: (expected: %s) (actual: %s)", test.name, test.expectedOutput, output)
+		}
+	}
+}
+
+func TestCommentText(t *testing.T) {
+	testCases := []testCase{
+		{
+			expectedOutput: "user was not found",
+			input:          " user was not found\n",
+			name:           "single line",
+		},
+		{
+			expectedOutput: "returned when the user lookup fails.\n//\n// Check the user id.",
+			input:          "returned when the user lookup fails.  \n\nCheck the user id.",
+			name:           "multiple lines",
+		},
+	}
+	for _, test := range testCases {
+		output := CommentText(test.input)
+		if output != test.expectedOutput {
+			t.Errorf("%s test failed: output not expected: (expected: %s) (actual: %s)", test.name, test.expectedOutput, output)
+		}
+	}
+}
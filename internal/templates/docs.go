@@ -0,0 +1,16 @@
+package templates
+
+const (
+	DocsTemplate = `# Error Catalog
+{{ range . }}
+{{ if .Title }}## {{ markdownCell .Title }}
+
+{{ end -}}
+| Code | Message | Tags | Metadata |
+| --- | --- | --- | --- |
+{{ range .Errors -}}
+| ` + "`{{ markdownCell .Code }}`" + ` | {{ markdownCell .Message }} | {{ markdownTags .Tags }} | {{ markdownMetaData .MetaData }} |
+{{ end -}}
+{{ end -}}
+`
+)
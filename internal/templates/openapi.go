@@ -0,0 +1,69 @@
+package templates
+
+const (
+	OpenAPITemplate = `openapi: 3.0.3
+info:
+  title: Error Responses
+  version: 1.0.0
+paths: {}
+components:
+  schemas:
+  {{- range . }}
+    {{ .Code }}Error:
+      type: object
+      required:
+        - code
+        - message
+      properties:
+        code:
+          type: string
+          enum:
+            - {{ yamlString .Code }}
+        message:
+          type: string
+          example: {{ yamlString .Message }}
+        occurredAt:
+          type: string
+          format: date-time
+        tags:
+          type: array
+          items:
+            type: string
+        innerErrors:
+          type: array
+          items:
+            type: object
+        metaData:
+          type: object
+          {{- with nonErrorMetaData .MetaData }}
+          properties:
+          {{- range . }}
+            {{ .Name }}:
+{{ openAPISchema .DataType 14 }}
+          {{- end }}
+          {{- end }}
+          {{- if .IncludeMap }}
+          additionalProperties: true
+          {{- end }}
+  {{- end }}
+  responses:
+  {{- range . }}
+    {{ .Code }}Error:
+      description: {{ yamlString .Message }}
+      x-http-status: {{ httpStatus .HTTPStatus }}
+      content:
+        application/json:
+          schema:
+            $ref: '#/components/schemas/{{ .Code }}Error'
+          example:
+            code: {{ yamlString .Code }}
+            message: {{ yamlString .Message }}
+            {{- if .Tags }}
+            tags:
+            {{- range .Tags }}
+              - {{ yamlString . }}
+            {{- end }}
+            {{- end }}
+  {{- end }}
+`
+)
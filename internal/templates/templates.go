@@ -7,19 +7,41 @@ package {{ .ErrorPkg }}
 /* WARNING: This is GENERATED CODE Please do not edit. */
 
 import (
+	{{ if getMessagePlaceholders .Message -}}
+		"fmt"
+	{{ end -}}
 	"github.com/calvine/richerror/errors"
+	{{ if .CodePkg -}}
+		"{{- .CodeImportPath -}}"
+	{{ end }}
 
-	{{ range getDataItemImportMap .MetaData -}}
-		"{{- . -}}"
+	{{ range .Imports -}}
+		{{ if .Alias }}{{ .Alias }} {{ end }}"{{- .Path -}}"
 	{{ end }}
 )
 
-// ErrCode{{ .Code }} {{ .Message }}
+{{ template "errorConstructorBody" . }}
+`
+
+	// ErrorConstructorBodyTemplate defines the constant, constructor and helpers for an error without the package clause
+	// so they can be rendered in a file per error or all together in a single file. The builder calls shared by the
+	// constructor and the sentinel are defined once in errorBuilders.
+	ErrorConstructorBodyTemplate = `
+{{ define "errorConstructorBody" }}
+// ErrCode{{ .Code }} {{ commentText (or .Description .Message) }}
+{{- if .CodePkg }}
+const ErrCode{{ .Code }} = {{ .CodePkg }}.ErrCode{{ .Code }}
+{{- else }}
 const ErrCode{{ .Code }} = "{{ .Code }}"
+{{- end }}
 
+{{- if .Description }}
+// New{{ .Code }}Error creates a new {{ .Code }} error. {{ commentText .Description }}
+{{- else }}
 // New{{ .Code }}Error creates a new specific error
+{{- end }}
 func New{{ .Code }}Error({{ range .MetaData }}{{ .Name }} {{ .DataType }}, {{ end }}{{ if .IncludeMap }}fields map[string]interface{}, {{ end }}includeStack bool) errors.RichError {
-	msg := "{{ .Message }}"
+	msg := {{ getMessageExpression .Message }}
 	err := errors.NewRichError(ErrCode{{ .Code }}, msg)
 	{{- if .IncludeMap -}}
 		.WithMetaData(fields)
@@ -31,34 +53,173 @@ func New{{ .Code }}Error({{ range .MetaData }}{{ .Name }} {{ .DataType }}, {{ en
 		.AddMetaData("{{ .Name }}", {{ .Name }})
 	{{- end -}}
 	{{- end -}}
-	{{- if .Tags -}}
-		.WithTags([]string{
-		{{- range .Tags -}}
-			"{{- . -}}",
-		{{- end -}}
-	})
-	{{- end }}
+	{{- template "errorBuilders" . }}
 	if includeStack {
 		err = err.WithStack(1)
 	}
 	return err
 }
+{{- if and .EmitSentinel (not .MetaData) (not .IncludeMap) }}
+
+// Err{{ .Code }} is a sentinel {{ .Code }} error. Errors created with New{{ .Code }}Error match it with errors.Is.
+var Err{{ .Code }} = errors.NewRichError(ErrCode{{ .Code }}, {{ printf "%q" .Message }})
+	{{- template "errorBuilders" . }}
+{{- end }}
 
 func Is{{ .Code }}Error(err errors.ReadOnlyRichError) bool {
 	return err.GetErrorCode() == ErrCode{{ .Code }}
 }
 
+{{ end }}
+
+{{ define "errorBuilders" -}}
+	{{- if .Tags -}}
+		.WithTags([]string{
+		{{- range .Tags -}}
+			{{ printf "%q" . }},
+		{{- end -}}
+	})
+	{{- end -}}
+	{{- if .HTTPStatus -}}
+		.WithHTTPStatus({{ .HTTPStatus }})
+	{{- end -}}
+	{{- if .GRPCCode -}}
+		.WithGRPCCode({{ .GRPCCode }})
+	{{- end -}}
+	{{- if .Category -}}
+		.WithCategory({{ printf "%q" .Category }})
+	{{- end -}}
+	{{- if .OutputFormat -}}
+		.SetOutputFormat(errors.{{ .OutputFormat }})
+	{{- end -}}
+{{ end }}
+`
+
+	SingleFileTemplate = `
+package {{ .ErrorPkg }}
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	{{ if .UsesFmt -}}
+		"fmt"
+	{{ end -}}
+	"github.com/calvine/richerror/errors"
+	{{ if .CodePkg -}}
+		"{{- .CodeImportPath -}}"
+	{{ end }}
+
+	{{ range .Imports -}}
+		{{ if .Alias }}{{ .Alias }} {{ end }}"{{- .Path -}}"
+	{{ end }}
+)
+{{ range .Errors }}
+{{ template "errorConstructorBody" . }}
+{{ end }}
+`
+
+	ErrorTestTemplate = `
+package {{ .ErrorPkg }}
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import (
+	"fmt"
+	"testing"
+
+	{{ range .Imports -}}
+		{{ if .Alias }}{{ .Alias }} {{ end }}"{{- .Path -}}"
+	{{ end }}
+)
+
+func TestNew{{ .Code }}Error(t *testing.T) {
+	err := New{{ .Code }}Error({{ range .MetaData }}*new({{ .DataType }}), {{ end }}{{ if .IncludeMap }}nil, {{ end }}false)
+	if err.GetErrorCode() != ErrCode{{ .Code }} {
+		t.Errorf("error code not expected: (expected: %s) (actual: %s)", ErrCode{{ .Code }}, err.GetErrorCode())
+	}
+	expectedTags := []string{ {{- range .Tags }}{{ printf "%q" . }}, {{ end -}} }
+	if fmt.Sprint(err.GetTags()) != fmt.Sprint(expectedTags) {
+		t.Errorf("error tags not expected: (expected: %v) (actual: %v)", expectedTags, err.GetTags())
+	}
+	if !Is{{ .Code }}Error(err) {
+		t.Errorf("Is{{ .Code }}Error should report true for an error created by New{{ .Code }}Error")
+	}
+}
+`
+
+	HTTPStatusMapTemplate = `
+package {{ .ErrorPkg }}
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+import "net/http"
+
+// HTTPStatusForCode returns the HTTP status defined for an error code. Unknown codes return http.StatusInternalServerError.
+func HTTPStatusForCode(code string) int {
+	switch code {
+	{{- range .Errors }}
+	{{- if .HTTPStatus }}
+	case "{{ .Code }}":
+		return {{ .HTTPStatus }}
+	{{- end }}
+	{{- end }}
+	}
+	return http.StatusInternalServerError
+}
 `
 
-// TODO: determine if we want the error code in a seperate package.
+	RegistryTemplate = `
+package {{ .ErrorPkg }}
+
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+// ErrorDescriptor describes a generated error for documentation and validation.
+type ErrorDescriptor struct {
+	Code        string
+	Message     string
+	Tags        []string
+	MetaData    []ErrorDescriptorMetaData
+	HTTPStatus  int
+	Constructor string
+}
+
+// ErrorDescriptorMetaData describes a metadata item required by an error constructor.
+type ErrorDescriptorMetaData struct {
+	Name     string
+	DataType string
+}
 
-// 	ErrorCodeTemplate = `
-// package {{ .CodePkg }}
+// Registry maps every generated error code to its descriptor.
+var Registry = map[string]ErrorDescriptor{
+	{{- range .Errors }}
+	{{ printf "%q" .Code }}: {
+		Code:    {{ printf "%q" .Code }},
+		Message: {{ printf "%q" .Message }},
+		Tags:    []string{ {{- range .Tags }}{{ printf "%q" . }}, {{ end -}} },
+		MetaData: []ErrorDescriptorMetaData{
+			{{- range .MetaData }}
+			{Name: {{ printf "%q" .Name }}, DataType: {{ printf "%q" .DataType }}},
+			{{- end }}
+		},
+		HTTPStatus:  {{ .HTTPStatus }},
+		Constructor: "New{{ .Code }}Error",
+	},
+	{{- end }}
+}
 
-// /* WARNING: This is GENERATED CODE Please do not edit. */
+// IsKnownErrorCode reports whether the code belongs to a generated error.
+func IsKnownErrorCode(code string) bool {
+	_, ok := Registry[code]
+	return ok
+}
+`
 
-// // ErrCode{{ .Code }} {{ .Message }}
-// const ErrCode{{ .Code }} = "{{ .Code }}"
+	ErrorCodeTemplate = `
+package {{ .CodePkg }}
 
-// `
+/* WARNING: This is GENERATED CODE Please do not edit. */
+
+// ErrCode{{ .Code }} {{ commentText (or .Description .Message) }}
+const ErrCode{{ .Code }} = "{{ .Code }}"
+`
 )
@@ -0,0 +1,80 @@
+// Package otel records rich errors on OpenTelemetry spans. It is a separate module so the errors
+// package does not depend on OpenTelemetry.
+package otel
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/calvine/richerror/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// CodeAttributeKey is the span attribute the error code is recorded as.
+	CodeAttributeKey = "error.code"
+	// MessageAttributeKey is the span attribute the error message is recorded as.
+	MessageAttributeKey = "error.message"
+	// MetaDataAttributePrefix is prepended to metadata keys so they do not collide with other span attributes.
+	MetaDataAttributePrefix = "error.metadata."
+)
+
+// RecordOnSpan sets the span status to Error with the error message, adds the error code, message and metadata as
+// span attributes and records the error as a span event with its captured stack as the exception stacktrace.
+// Metadata keys the error redacts are recorded as errors.RedactedValue.
+func RecordOnSpan(span trace.Span, err errors.ReadOnlyRichError) {
+	if err == nil {
+		return
+	}
+	span.SetStatus(codes.Error, err.GetErrorMessage())
+	attrs := []attribute.KeyValue{
+		attribute.String(CodeAttributeKey, err.GetErrorCode()),
+		attribute.String(MessageAttributeKey, err.GetErrorMessage()),
+	}
+	metaData := err.GetMetaData()
+	if redactable, ok := err.(interface{ RedactMetaData() errors.RichError }); ok {
+		metaData = redactable.RedactMetaData().GetMetaData()
+	}
+	for key, value := range metaData {
+		attrs = append(attrs, metaDataAttribute(MetaDataAttributePrefix+key, value))
+	}
+	span.SetAttributes(attrs...)
+	eventOptions := []trace.EventOption{trace.WithAttributes(attrs...)}
+	if err.HasStack() {
+		eventOptions = append(eventOptions, trace.WithAttributes(attribute.String("exception.stacktrace", stackTrace(err))))
+	}
+	span.RecordError(err, eventOptions...)
+}
+
+// metaDataAttribute converts a metadata value to the matching attribute type, falling back to its fmt.Sprint representation.
+func metaDataAttribute(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case []string:
+		return attribute.StringSlice(key, v)
+	case fmt.Stringer:
+		return attribute.String(key, v.String())
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
+
+// stackTrace renders the stack in the function then file:line layout of Go panics, which tracing backends recognize.
+func stackTrace(err errors.ReadOnlyRichError) string {
+	var stackBuilder strings.Builder
+	for _, frame := range err.GetStack() {
+		stackBuilder.WriteString(fmt.Sprintf("%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line))
+	}
+	return stackBuilder.String()
+}
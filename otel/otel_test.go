@@ -0,0 +1,63 @@
+package otel
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/calvine/richerror/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRecordOnSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("richerror")
+	_, span := tracer.Start(context.Background(), "operation")
+	err := errors.NewRichError("NotFound", "user not found").
+		WithStack(0).
+		WithRedactedKeys("password").
+		WithMetaData(map[string]interface{}{"userId": "calvine", "attempts": 3, "password": "hunter2"})
+	RecordOnSpan(span, err)
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected one ended span (actual: %d)", len(spans))
+	}
+	recordedSpan := spans[0]
+	if recordedSpan.Status().Code != codes.Error || recordedSpan.Status().Description != "user not found" {
+		t.Errorf("span status not expected: (expected: Error user not found) (actual: %v %s)", recordedSpan.Status().Code, recordedSpan.Status().Description)
+	}
+	expectedAttributes := map[attribute.Key]attribute.Value{
+		CodeAttributeKey:                     attribute.StringValue("NotFound"),
+		MessageAttributeKey:                  attribute.StringValue("user not found"),
+		MetaDataAttributePrefix + "userId":   attribute.StringValue("calvine"),
+		MetaDataAttributePrefix + "attempts": attribute.IntValue(3),
+		MetaDataAttributePrefix + "password": attribute.StringValue(errors.RedactedValue),
+	}
+	actualAttributes := make(map[attribute.Key]attribute.Value)
+	for _, attr := range recordedSpan.Attributes() {
+		actualAttributes[attr.Key] = attr.Value
+	}
+	for key, expected := range expectedAttributes {
+		if actual, ok := actualAttributes[key]; !ok || actual != expected {
+			t.Errorf("span attribute %s not expected: (expected: %s) (actual: %s)", key, expected.Emit(), actual.Emit())
+		}
+	}
+	events := recordedSpan.Events()
+	if len(events) != 1 || events[0].Name != "exception" {
+		t.Fatalf("expected one exception event: (actual: %v)", events)
+	}
+	stackTraceFound := false
+	for _, attr := range events[0].Attributes {
+		if attr.Key == "exception.stacktrace" {
+			stackTraceFound = strings.Contains(attr.Value.AsString(), "otel.TestRecordOnSpan\n\t")
+		}
+	}
+	if !stackTraceFound {
+		t.Errorf("exception event missing the rich error stack: %v", events[0].Attributes)
+	}
+}